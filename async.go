@@ -0,0 +1,260 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// QueuePolicy enumerates what an asynchronous LeveledLogger does with a new
+// message when its queue is full.  See WithAsync.
+type QueuePolicy byte
+
+const (
+	// QueueBlock waits until there is room in the queue.
+	QueueBlock QueuePolicy = iota
+
+	// QueueDropNewest drops the new message.
+	QueueDropNewest
+
+	// QueueDropOldest drops the oldest message of the queue to make room for
+	// the new message.
+	QueueDropOldest
+
+	// QueueDropBelow drops the new message if its level is below the level set
+	// with WithQueueDropLevel, Error by default, and waits otherwise.
+	QueueDropBelow
+)
+
+// WithAsync returns an Option that configures the LeveledLogger to write
+// messages from a separate goroutine, so that logging does not wait for slow
+// outputs.  Messages are still filtered and encoded by the logging methods,
+// and are then added to a queue holding up to queueSize messages, or handled
+// according to policy if the queue is full.  The number of dropped messages
+// is reported by Dropped.
+//
+// Messages keep the time they were logged at, including in the timestamp
+// prefix of the log.Logger created for WithOutput or stderr, which the
+// LeveledLogger then writes itself.  The prefix added by a log.Logger provided
+// with WithLogger holds the time messages are written at.
+//
+// Flush waits until the queued messages are written, and Close must be called
+// before the program exits so that no message is lost.
+func WithAsync(queueSize int, policy QueuePolicy) Option {
+	return func(l *LeveledLogger) {
+		l.queueSize = queueSize
+		l.queuePolicy = policy
+	}
+}
+
+// WithQueueDropLevel returns an Option that sets the level of the QueueDropBelow
+// policy: when the queue is full, messages below the given level are dropped.
+func WithQueueDropLevel(lvl level.Level) Option {
+	return func(l *LeveledLogger) {
+		l.queueDropLevel = lvl
+	}
+}
+
+// asyncQueue holds the messages written by the goroutine of an asynchronous
+// LeveledLogger.
+type asyncQueue struct {
+	items   chan asyncItem
+	done    chan struct{}
+	dropped uint64
+
+	// mu is held for reading while sending to items, and for writing while
+	// closing it.
+	mu     sync.RWMutex
+	closed bool
+
+	// evicted holds the flush requests removed from items by the
+	// QueueDropOldest policy, which are notified once the goroutine writes the
+	// next message, as the messages queued before them are then written or
+	// dropped.
+	evictedMu sync.Mutex
+	evicted   []chan struct{}
+}
+
+// asyncItem is either a message to write, or a request to be notified, by
+// closing flushed, once the messages queued before are written.
+type asyncItem struct {
+	line    []byte
+	flushed chan struct{}
+}
+
+// startAsync starts the goroutine writing the queued messages.
+func (l *LeveledLogger) startAsync() {
+	q := &asyncQueue{
+		items: make(chan asyncItem, l.queueSize),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(q.done)
+		defer q.notifyEvicted()
+
+		for item := range q.items {
+			if item.flushed != nil {
+				close(item.flushed)
+			} else {
+				l.logger.Print(string(item.line))
+			}
+
+			q.notifyEvicted()
+		}
+	}()
+
+	l.queue = q
+}
+
+// write writes the line, or queues it if the logger is asynchronous.  Lines
+// logged after Close are written synchronously.
+func (l *LeveledLogger) write(lvl level.Level, line []byte) {
+	q := l.queue
+	if q == nil {
+		l.logger.Print(string(line))
+
+		return
+	}
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		l.logger.Print(string(line))
+
+		return
+	}
+
+	item := asyncItem{line: line}
+
+	switch {
+	case l.queuePolicy == QueueDropNewest,
+		l.queuePolicy == QueueDropBelow && lvl < l.queueDropLevel:
+		select {
+		case q.items <- item:
+		default:
+			atomic.AddUint64(&q.dropped, 1)
+		}
+
+	case l.queuePolicy == QueueDropOldest:
+		select {
+		case q.items <- item:
+			return
+		default:
+		}
+
+		// The queue is full: make room by removing the oldest item, unless the
+		// goroutine just did, and wait in the unlikely case that other messages
+		// took the room first.
+		select {
+		case old := <-q.items:
+			q.evict(old)
+		default:
+		}
+
+		q.items <- item
+
+	default:
+		q.items <- item
+	}
+}
+
+// evict handles an item removed from the queue to make room for a new message.
+// Flush requests are kept until the goroutine writes its next message, other
+// items are counted as dropped.
+func (q *asyncQueue) evict(item asyncItem) {
+	if item.flushed == nil {
+		atomic.AddUint64(&q.dropped, 1)
+
+		return
+	}
+
+	q.evictedMu.Lock()
+	q.evicted = append(q.evicted, item.flushed)
+	q.evictedMu.Unlock()
+}
+
+// notifyEvicted notifies the flush requests removed from the queue by evict.
+func (q *asyncQueue) notifyEvicted() {
+	q.evictedMu.Lock()
+	defer q.evictedMu.Unlock()
+
+	for _, flushed := range q.evicted {
+		close(flushed)
+	}
+
+	q.evicted = nil
+}
+
+// Dropped returns the number of messages dropped because the queue of an
+// asynchronous LeveledLogger was full.  See WithAsync.
+func (l *LeveledLogger) Dropped() uint64 {
+	if l.queue == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&l.queue.dropped)
+}
+
+// Flush waits until the messages logged before the call are written.  It
+// returns immediately if the LeveledLogger is not asynchronous.
+func (l *LeveledLogger) Flush() {
+	q := l.queue
+	if q == nil {
+		return
+	}
+
+	q.mu.RLock()
+
+	if q.closed {
+		q.mu.RUnlock()
+
+		return
+	}
+
+	flushed := make(chan struct{})
+	q.items <- asyncItem{flushed: flushed}
+	q.mu.RUnlock()
+
+	<-flushed
+}
+
+// Close writes the queued messages and stops the goroutine of an asynchronous
+// LeveledLogger.  Messages logged after Close are written synchronously.  It is
+// safe to call Close more than once, and it does nothing if the LeveledLogger
+// is not asynchronous.
+func (l *LeveledLogger) Close() {
+	q := l.queue
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.items)
+	}
+
+	q.mu.Unlock()
+
+	<-q.done
+}
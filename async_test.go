@@ -0,0 +1,273 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// blockingWriter blocks the first write until release is closed.
+type blockingWriter struct {
+	mu      sync.Mutex
+	b       bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.b.Write(p)
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.b.String()
+}
+
+func TestWithAsync(t *testing.T) {
+	tests := map[string]struct {
+		policy  QueuePolicy
+		want    string
+		dropped uint64
+	}{
+		"drop_newest": {
+			QueueDropNewest,
+			"0 1 2",
+			3,
+		},
+		"drop_oldest": {
+			QueueDropOldest,
+			"0 4 5",
+			3,
+		},
+		"drop_below": {
+			QueueDropBelow,
+			"0 1 2 5",
+			2,
+		},
+	}
+
+	for name, tc := range tests {
+		w := newBlockingWriter()
+		l := NewLeveledLogger(WithOutput(w), WithFilterLevel(level.All), WithEncoder(NewLogfmtEncoder()),
+			WithTimestamp(TimestampNone), WithAsync(2, tc.policy))
+
+		// The first message blocks the writer, and the next two fill the queue.
+		l.Info("msg", 0)
+		<-w.started
+		l.Info("msg", 1)
+		l.Info("msg", 2)
+
+		l.Debug("msg", 3)
+		l.Info("msg", 4)
+
+		if tc.policy == QueueDropBelow {
+			// Error messages wait for room in the queue.
+			done := make(chan struct{})
+
+			go func() {
+				l.Error("msg", 5)
+				close(done)
+			}()
+
+			close(w.release)
+			<-done
+		} else {
+			l.Error("msg", 5)
+			close(w.release)
+		}
+
+		l.Flush()
+
+		var got []string
+
+		for _, line := range strings.Split(strings.TrimSpace(w.String()), "\n") {
+			got = append(got, strings.TrimPrefix(line[strings.Index(line, "msg="):], "msg="))
+		}
+
+		if strings.Join(got, " ") != tc.want {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+
+		if l.Dropped() != tc.dropped {
+			t.Errorf("%s: got %d dropped, want %d", name, l.Dropped(), tc.dropped)
+		}
+
+		l.Close()
+		l.Close()
+		l.Info("msg", "closed")
+
+		if !strings.HasSuffix(w.String(), "msg=closed\n") {
+			t.Errorf("%s: message logged after Close not written", name)
+		}
+	}
+}
+
+func TestWithAsync_block(t *testing.T) {
+	w := newBlockingWriter()
+	l := NewLeveledLogger(WithOutput(w), WithEncoder(NewLogfmtEncoder()), WithTimestamp(TimestampNone),
+		WithAsync(1, QueueBlock))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+			l.Info("msg", i)
+		}(i)
+	}
+
+	<-w.started
+	close(w.release)
+	wg.Wait()
+	l.Close()
+
+	if n := strings.Count(w.String(), "\n"); n != 10 {
+		t.Errorf("got %d lines, want 10", n)
+	}
+
+	if l.Dropped() != 0 {
+		t.Errorf("got %d dropped, want 0", l.Dropped())
+	}
+}
+
+func TestWithAsync_stdTimestamp(t *testing.T) {
+	w := newBlockingWriter()
+	l := NewLeveledLogger(WithOutput(w), WithEncoder(NewLogfmtEncoder()), WithAsync(1, QueueBlock))
+
+	before := time.Now()
+
+	l.Info("msg", "first")
+	<-w.started
+
+	after := time.Now()
+
+	// The message is written once the timestamp of the write would differ.
+	time.Sleep(time.Until(after.Truncate(time.Second).Add(time.Second)))
+	close(w.release)
+	l.Close()
+
+	got := w.String()
+	if got != before.Format(stdTimeFormat)+"level=info msg=first\n" && got != after.Format(stdTimeFormat)+"level=info msg=first\n" {
+		t.Errorf("got %q, want the time of the Info call as prefix, between %v and %v", got, before, after)
+	}
+}
+
+// slowWriter counts the writes, which take a short while.
+type slowWriter struct {
+	mu    sync.Mutex
+	lines int
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(10 * time.Microsecond)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lines++
+
+	return len(p), nil
+}
+
+func TestWithAsync_dropOldestFlush(t *testing.T) {
+	const writers, messages = 4, 500
+
+	w := &slowWriter{}
+	l := NewLeveledLogger(WithOutput(w), WithEncoder(NewLogfmtEncoder()), WithTimestamp(TimestampNone),
+		WithAsync(1, QueueDropOldest))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < messages; j++ {
+				l.Info("msg", j)
+			}
+		}()
+	}
+
+	logged := make(chan struct{})
+	flushed := make(chan struct{})
+
+	go func() {
+		defer close(flushed)
+
+		for {
+			select {
+			case <-logged:
+				return
+			default:
+				l.Flush()
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(logged)
+
+	select {
+	case <-flushed:
+	case <-time.After(30 * time.Second):
+		t.Fatal("Flush did not return")
+	}
+
+	l.Close()
+
+	if got := uint64(w.lines) + l.Dropped(); got != writers*messages {
+		t.Errorf("got %d lines and %d dropped, want %d messages in total", w.lines, l.Dropped(), writers*messages)
+	}
+}
+
+func TestLeveledLogger_Flush_sync(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithOutput(&b))
+
+	l.Info("msg", "sync")
+	l.Flush()
+	l.Close()
+
+	if b.Len() == 0 || l.Dropped() != 0 {
+		t.Errorf("unexpected output %q or dropped count %d", b.String(), l.Dropped())
+	}
+}
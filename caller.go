@@ -0,0 +1,203 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"path"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallerKey is the default key of the field added by the WithCaller and
+// WithCallerFunction options.  See WithKeyNames.
+const CallerKey = "caller"
+
+// WithCaller returns an Option that configures the LeveledLogger to add a
+// CallerKey field to each message, holding the file and line of the code that
+// called the logging method, e.g. "server/server.go:42".
+//
+// Calls made by the Loggers of this package, such as those created by With and
+// Named, are skipped when looking for the caller, as are calls made by the log
+// and log/slog packages and by functions that called Helper.
+func WithCaller() Option {
+	return func(l *LeveledLogger) {
+		l.caller = callerFile
+	}
+}
+
+// WithCallerFunction is like WithCaller, but the caller field also contains
+// the name of the calling function, e.g. "server/server.go:42 server.(*Server).Run".
+func WithCallerFunction() Option {
+	return func(l *LeveledLogger) {
+		l.caller = callerFileFunction
+	}
+}
+
+// callerMode enumerates the ways the caller of logging methods is reported.
+type callerMode byte
+
+const (
+	callerNone callerMode = iota
+	callerFile
+	callerFileFunction
+)
+
+// helpers holds the names of the functions that called Helper.
+var helpers sync.Map
+
+// Helper marks the calling function as a logging helper function, similar to
+// testing.TB.Helper.  When looking for the caller to report in the field added
+// by WithCaller, the frames of helper functions are skipped.  Helper may be
+// called any number of times and from multiple goroutines.
+func Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 { //nolint:gomnd // skip runtime.Callers and Helper
+		return
+	}
+
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	helpers.Store(frame.Function, struct{}{})
+}
+
+// pkgPrefix is the prefix of the names of functions in this package, e.g.
+// "github.com/cobaltspeech/log.".
+var pkgPrefix = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(With).Pointer()).Name(), "With")
+
+// pkgPrefixes are the prefixes of the names of the functions skipped when
+// looking for the caller, except in test files: those of this package and of
+// the sloghandler package, which forwards the records of slog Loggers.
+var pkgPrefixes = []string{pkgPrefix, strings.TrimSuffix(pkgPrefix, ".") + "/pkg/sloghandler."}
+
+// forwardingPrefixes are the prefixes of the names of the functions of the
+// standard library packages forwarding messages to Loggers, which are skipped
+// like the functions of this package.
+var forwardingPrefixes = []string{"log.", "log/slog."}
+
+// maxCallerDepth bounds the number of frames skipped when searching for the
+// caller.
+const maxCallerDepth = 64
+
+// callerFrames returns up to limit frames of the stack of the current
+// goroutine, starting with the first frame that is neither in this package,
+// excluding test files, nor a helper function.
+func callerFrames(limit int) []runtime.Frame {
+	pcs := make([]uintptr, maxCallerDepth+limit)
+	n := runtime.Callers(2, pcs) //nolint:gomnd // skip runtime.Callers and callerFrames
+
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var out []runtime.Frame
+
+	for len(out) < limit {
+		frame, more := frames.Next()
+
+		if len(out) > 0 || !skipFrame(frame) {
+			out = append(out, frame)
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return out
+}
+
+// skipFrame reports whether the frame must be skipped when looking for the
+// caller of the logging methods.
+func skipFrame(frame runtime.Frame) bool {
+	if !strings.HasSuffix(frame.File, "_test.go") {
+		for _, prefix := range pkgPrefixes {
+			if strings.HasPrefix(frame.Function, prefix) {
+				return true
+			}
+		}
+	}
+
+	for _, prefix := range forwardingPrefixes {
+		if strings.HasPrefix(frame.Function, prefix) {
+			return true
+		}
+	}
+
+	_, ok := helpers.Load(frame.Function)
+
+	return ok
+}
+
+// callerOverride is the value of a CallerKey field holding the caller of a
+// message known by the code logging it, such as the file and line parsed by a
+// StdlibWriter.  The LeveledLogger reports it instead of looking for the
+// caller.  The noCaller override removes the caller field of messages that
+// have no meaningful caller, such as the summaries logged by timers.
+type callerOverride string
+
+// noCaller is the callerOverride of messages without a caller.
+const noCaller callerOverride = ""
+
+func (c callerOverride) String() string {
+	return string(c)
+}
+
+// takeCallerOverride returns the callerOverride of the keyvals, if any, and
+// the keyvals without its field.
+func takeCallerOverride(keyvals []interface{}) (string, []interface{}, bool) {
+	for i := 1; i < len(keyvals); i += 2 {
+		if c, ok := keyvals[i].(callerOverride); ok {
+			kvs := make([]interface{}, 0, len(keyvals)-2) //nolint:gomnd // key and value
+			kvs = append(kvs, keyvals[:i-1]...)
+			kvs = append(kvs, keyvals[i+1:]...)
+
+			return string(c), kvs, true
+		}
+	}
+
+	return "", keyvals, false
+}
+
+// callerString formats the caller of the logging method according to mode.
+func callerString(mode callerMode) string {
+	frames := callerFrames(1)
+	if len(frames) == 0 {
+		return "unknown"
+	}
+
+	s := shortFile(frames[0].File) + ":" + strconv.Itoa(frames[0].Line)
+
+	if mode == callerFileFunction {
+		s += " " + shortFunction(frames[0].Function)
+	}
+
+	return s
+}
+
+// shortFile returns the last directory and the file name of the path.
+func shortFile(file string) string {
+	dir, name := path.Split(file)
+
+	return path.Join(path.Base(dir), name)
+}
+
+// shortFunction strips the import path of the package from the function
+// name, e.g. "github.com/org/server.(*Server).Run" becomes
+// "server.(*Server).Run".
+func shortFunction(function string) string {
+	return function[strings.LastIndexByte(function, '/')+1:]
+}
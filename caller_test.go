@@ -0,0 +1,104 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// callerLine returns the caller field expected for a logging call made on the
+// line following the call to callerLine.
+func callerLine(t *testing.T) string {
+	t.Helper()
+
+	_, file, line, ok := runtime.Caller(1)
+	if !ok {
+		t.Fatal("could not get caller")
+	}
+
+	return fmt.Sprintf("%s/%s:%d", filepath.Base(filepath.Dir(file)), filepath.Base(file), line+1)
+}
+
+// logWithHelper is a wrapper that marks itself as a helper.
+func logWithHelper(l Logger, msg string) {
+	Helper()
+	l.Info("msg", msg)
+}
+
+// logWithoutHelper is a wrapper that does not mark itself as a helper.  It
+// returns the caller field expected for its message.
+func logWithoutHelper(t *testing.T, l Logger, msg string) string {
+	t.Helper()
+
+	want := callerLine(t)
+	l.Info("msg", msg)
+
+	return want
+}
+
+func TestWithCaller(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithCaller())
+
+	var want []string
+
+	want = append(want, callerLine(t))
+	l.Info("msg", "direct")
+
+	want = append(want, callerLine(t))
+	With(l, "key", "value").Info("msg", "with")
+
+	want = append(want, callerLine(t))
+	Named(With(Named(l, "a"), "key", "value"), "b").Info("msg", "named")
+
+	want = append(want, callerLine(t))
+	logWithHelper(With(l, "key", "value"), "helper")
+
+	want = append(want, logWithoutHelper(t, l, "no helper"))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), b.String())
+	}
+
+	for i, line := range lines {
+		if !strings.Contains(line, fmt.Sprintf(`"caller":%q`, want[i])) {
+			t.Errorf("line %d: got %s, want caller %q", i, line, want[i])
+		}
+	}
+}
+
+func TestWithCallerFunction(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithCallerFunction())
+
+	want := callerLine(t) + " log.TestWithCallerFunction"
+	With(l, "key", "value").Info("msg", "with")
+
+	got := b.String()
+	wantLine := fmt.Sprintf(`info  {"caller":%q,"key":"value","msg":"with"}`+"\n", want)
+
+	if got != wantLine {
+		t.Errorf("got %q, want %q", got, wantLine)
+	}
+}
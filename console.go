@@ -0,0 +1,177 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// consoleMessageWidth is the width the message is padded to, so that the
+// remaining fields of consecutive lines start in the same column.
+const consoleMessageWidth = 40
+
+// ANSI escape sequences used by the ConsoleEncoder.
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+var levelColors = map[level.Level]string{
+	level.Error: colorRed,
+	level.Info:  colorBlue,
+	level.Debug: colorMagenta,
+	level.Trace: colorGray,
+}
+
+// ConsoleEncoder is an Encoder meant for humans reading logs in a terminal.
+// It writes the level, followed by the message padded to a fixed width, followed
+// by the remaining keyvals as key=value pairs:
+//
+//	info  server started                           port=8080 addr=":80"
+//
+// The message is the value keyed by the Message of the Entry's key names.
+// Values are formatted and quoted the same way as by the LogfmtEncoder, and the
+// message is quoted if it contains control characters such as newlines.  If
+// color is enabled, the level and keys are highlighted with ANSI escape codes.
+type ConsoleEncoder struct {
+	color bool
+}
+
+// NewConsoleEncoder returns a new ConsoleEncoder, using ANSI colors if color
+// is true.
+func NewConsoleEncoder(color bool) *ConsoleEncoder {
+	return &ConsoleEncoder{color: color}
+}
+
+// Encode implements the Encoder interface.
+func (enc *ConsoleEncoder) Encode(e *Entry) ([]byte, error) {
+	ms := logmap.FromKeyvals(e.Keyvals...)
+
+	var buf bytes.Buffer
+
+	enc.writeColored(&buf, levelColors[e.Level], fmt.Sprintf("%-5s", e.Level))
+
+	var msg string
+
+	messageKey := e.Keys.withDefaults().Message
+
+	for i := range ms {
+		if ms[i].Key != messageKey {
+			continue
+		}
+
+		val, err := logmap.TextFromValue(ms[i].Value)
+		if err != nil {
+			return nil, err
+		}
+
+		msg = consoleMessage(val)
+		ms = append(ms[:i], ms[i+1:]...)
+
+		break
+	}
+
+	buf.WriteByte(' ')
+
+	if len(ms) == 0 {
+		buf.WriteString(msg)
+		buf.WriteByte('\n')
+
+		return buf.Bytes(), nil
+	}
+
+	fmt.Fprintf(&buf, "%-*s", consoleMessageWidth, msg)
+
+	for _, item := range ms {
+		val, err := logmap.TextFromValue(item.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte(' ')
+		enc.writeColored(&buf, colorCyan, logfmtKey(item.Key)+"=")
+		buf.WriteString(logfmtValue(val))
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+func (enc *ConsoleEncoder) writeColored(buf *bytes.Buffer, color, s string) {
+	if !enc.color || color == "" {
+		buf.WriteString(s)
+
+		return
+	}
+
+	buf.WriteString(color)
+	buf.WriteString(s)
+	buf.WriteString(colorReset)
+}
+
+// defaultEncoder returns the Encoder used by a LeveledLogger writing to w when
+// none was configured: a colored ConsoleEncoder if w is a terminal, and a
+// JSONEncoder otherwise.  Colors are disabled if the NO_COLOR environment
+// variable is set.
+func defaultEncoder(w io.Writer) Encoder {
+	if !isTerminal(w) {
+		return NewJSONEncoder()
+	}
+
+	_, noColor := os.LookupEnv("NO_COLOR")
+
+	return NewConsoleEncoder(!noColor)
+}
+
+// isTerminal reports whether w is a file referring to a terminal.  Other
+// character devices, such as /dev/null, are not terminals.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	return isatty(f.Fd())
+}
+
+// consoleMessage quotes and escapes the message if it contains control or
+// invalid characters, so that messages cannot forge lines or escape sequences.
+func consoleMessage(msg string) string {
+	if strings.IndexFunc(msg, consoleNeedsQuote) >= 0 {
+		return strconv.Quote(msg)
+	}
+
+	return msg
+}
+
+func consoleNeedsQuote(r rune) bool {
+	return r < ' ' || r == 0x7f || r == utf8.RuneError
+}
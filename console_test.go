@@ -0,0 +1,107 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestConsoleEncoder(t *testing.T) {
+	tests := map[string]struct {
+		color bool
+		entry Entry
+		want  string
+	}{
+		"message_only": {
+			false,
+			Entry{Level: level.Info, Keyvals: []interface{}{"msg", "server started"}},
+			"info  server started\n",
+		},
+		"message_first": {
+			false,
+			Entry{Level: level.Error, Keyvals: []interface{}{"port", 8080, "msg", "bind failed", "addr", ":80 "}},
+			"error bind failed                              port=8080 addr=\":80 \"\n",
+		},
+		"no_message": {
+			false,
+			Entry{Level: level.Debug, Keyvals: []interface{}{"n", 1}},
+			"debug                                          n=1\n",
+		},
+		"control_characters": {
+			false,
+			Entry{Level: level.Info, Keyvals: []interface{}{"msg", "done\nerror forged", "n", 1}},
+			"info  \"done\\nerror forged\"                     n=1\n",
+		},
+		"color": {
+			true,
+			Entry{Level: level.Trace, Keyvals: []interface{}{"msg", "hi", "n", 1}},
+			"\x1b[90mtrace\x1b[0m hi                                       \x1b[36mn=\x1b[0m1\n",
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := NewConsoleEncoder(tc.color).Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestDefaultEncoder(t *testing.T) {
+	if _, ok := defaultEncoder(&bytes.Buffer{}).(*JSONEncoder); !ok {
+		t.Error("expected a JSONEncoder for a bytes.Buffer")
+	}
+
+	dir, err := ioutil.TempDir("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	if _, ok := defaultEncoder(f).(*JSONEncoder); !ok {
+		t.Error("expected a JSONEncoder for a regular file")
+	}
+
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer null.Close()
+
+	if _, ok := defaultEncoder(null).(*JSONEncoder); !ok {
+		t.Error("expected a JSONEncoder for the null device")
+	}
+}
@@ -0,0 +1,76 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import "context"
+
+// loggerKey and valuesKey are the keys of the Logger and keyvals stored in
+// contexts.
+type (
+	loggerKey struct{}
+	valuesKey struct{}
+)
+
+// NewContext returns a copy of ctx carrying the Logger, which can be retrieved
+// with FromContext, e.g. by the handlers of a request.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the Logger carried by ctx, or a DiscardLogger if ctx
+// does not carry one.  The keyvals added to ctx with WithContextValues are
+// prepended to those passed to the returned Logger, as by With.
+func FromContext(ctx context.Context) Logger {
+	l, ok := ctx.Value(loggerKey{}).(Logger)
+	if !ok {
+		l = NewDiscardLogger()
+	}
+
+	return WithContext(ctx, l)
+}
+
+// WithContextValues returns a copy of ctx carrying the keyvals, in addition to
+// those already carried by ctx.  The keyvals are added to the messages of the
+// Loggers returned by FromContext and WithContext, so that request-scoped
+// fields, such as a request ID, appear on every line.
+func WithContextValues(ctx context.Context, keyvals ...interface{}) context.Context {
+	if len(keyvals) == 0 {
+		return ctx
+	}
+
+	old := contextValues(ctx)
+
+	kvs := make([]interface{}, 0, len(old)+len(keyvals))
+	kvs = append(kvs, old...)
+	kvs = append(kvs, keyvals...)
+
+	return context.WithValue(ctx, valuesKey{}, kvs)
+}
+
+// WithContext returns a new contextual Logger with the keyvals carried by ctx
+// prepended to those passed to calls to the new logger, as by With.  It returns
+// l if ctx carries no keyvals.
+func WithContext(ctx context.Context, l Logger) Logger {
+	return With(l, contextValues(ctx)...)
+}
+
+// contextValues returns the keyvals carried by ctx.  They must not be modified.
+func contextValues(ctx context.Context) []interface{} {
+	kvs, _ := ctx.Value(valuesKey{}).([]interface{})
+
+	return kvs
+}
@@ -0,0 +1,57 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()).(*DiscardLogger); !ok {
+		t.Error("expected a DiscardLogger for a context without Logger")
+	}
+
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithOutput(&b), WithTimestamp(TimestampNone))
+
+	ctx := NewContext(context.Background(), l)
+	if FromContext(ctx) != Logger(l) {
+		t.Error("expected the Logger of the context")
+	}
+
+	ctx = WithContextValues(ctx, "request_id", "r1")
+	child := WithContextValues(ctx, "user", "u1")
+	other := WithContextValues(ctx, "user", "u2")
+
+	FromContext(ctx).Info("msg", "parent")
+	FromContext(child).Info("msg", "child")
+	FromContext(other).Info("msg", "other")
+	WithContext(child, Named(l, "db")).Error("msg", "query failed")
+
+	want := `info  {"request_id":"r1","msg":"parent"}
+info  {"request_id":"r1","user":"u1","msg":"child"}
+info  {"request_id":"r1","user":"u2","msg":"other"}
+error {"logger":"db","request_id":"r1","user":"u1","msg":"query failed"}`
+
+	if got := strings.TrimSpace(b.String()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
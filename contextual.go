@@ -31,6 +31,14 @@ type contextLogger struct {
 	keyvals []interface{}
 }
 
+func (c *contextLogger) loggerName() string {
+	if n, ok := c.log.(namer); ok {
+		return n.loggerName()
+	}
+
+	return ""
+}
+
 func (c *contextLogger) Error(keyvals ...interface{}) {
 	kvs := append(c.keyvals, keyvals...)
 	c.log.Error(kvs...)
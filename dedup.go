@@ -0,0 +1,232 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// RepeatedKey is the key of the field holding the number of repetitions of an
+// entry collapsed by a Logger returned by Deduplicated.
+const RepeatedKey = "repeated"
+
+// RepeatSpanKey is the key of the field holding the time elapsed between the
+// first and the last repetitions of an entry collapsed by a Logger returned by
+// Deduplicated.
+const RepeatSpanKey = "repeat_span"
+
+// Deduplicated returns a new Logger that collapses identical consecutive
+// entries logged to l.  Two entries are identical if they have the same level
+// and the same keys and values, as formatted by the Encoders of this package.
+//
+// The first entry is logged immediately, while its repetitions are only
+// counted.  When a different entry is logged, or after the given interval if
+// no different entry is logged before, the repetitions are flushed as a single
+// entry with the keys and values of the repeated entry, followed by a
+// RepeatedKey field holding the number of repetitions and a RepeatSpanKey
+// field holding the time between the first and last of them, without caller.
+//
+// Close must be called before the program exits so that the last repetitions
+// are not lost.
+func Deduplicated(l Logger, interval time.Duration) *DeduplicatedLogger {
+	return &DeduplicatedLogger{log: l, interval: interval, now: time.Now}
+}
+
+// DeduplicatedLogger is the Logger returned by Deduplicated.
+type DeduplicatedLogger struct {
+	log      Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	lvl      level.Level
+	key      string
+	keyvals  []interface{}
+	repeated int
+	first    time.Time
+	last     time.Time
+	run      uint64
+	timer    *time.Timer
+	closed   bool
+}
+
+func (d *DeduplicatedLogger) loggerName() string {
+	if n, ok := d.log.(namer); ok {
+		return n.loggerName()
+	}
+
+	return ""
+}
+
+// Error implements the Logger interface.
+func (d *DeduplicatedLogger) Error(keyvals ...interface{}) {
+	d.dedup(level.Error, keyvals)
+}
+
+// Info implements the Logger interface.
+func (d *DeduplicatedLogger) Info(keyvals ...interface{}) {
+	d.dedup(level.Info, keyvals)
+}
+
+// Debug implements the Logger interface.
+func (d *DeduplicatedLogger) Debug(keyvals ...interface{}) {
+	d.dedup(level.Debug, keyvals)
+}
+
+// Trace implements the Logger interface.
+func (d *DeduplicatedLogger) Trace(keyvals ...interface{}) {
+	d.dedup(level.Trace, keyvals)
+}
+
+// Flush logs the repetitions counted so far, if any, without waiting for the
+// end of the interval.
+func (d *DeduplicatedLogger) Flush() {
+	d.mu.Lock()
+	lvl, kvs, ok := d.summary()
+	d.mu.Unlock()
+
+	if ok {
+		logAt(d.log, lvl, kvs)
+	}
+}
+
+// Close flushes the repetitions counted so far and stops the timer of the
+// interval.  Entries logged after Close are logged without deduplication.  It
+// is safe to call Close more than once.
+func (d *DeduplicatedLogger) Close() {
+	d.mu.Lock()
+	d.closed = true
+	lvl, kvs, ok := d.summary()
+	d.mu.Unlock()
+
+	if ok {
+		logAt(d.log, lvl, kvs)
+	}
+}
+
+// dedup logs the entry unless it repeats the previous one, in which case the
+// flushed repetitions of the previous entry are logged first.  The entries are
+// logged without holding the lock.
+func (d *DeduplicatedLogger) dedup(lvl level.Level, keyvals []interface{}) {
+	// Entries that cannot be encoded are never considered identical.
+	key, err := logmap.FromKeyvals(keyvals...).JSONString()
+
+	d.mu.Lock()
+
+	if d.closed {
+		d.mu.Unlock()
+		logAt(d.log, lvl, keyvals)
+
+		return
+	}
+
+	now := d.now()
+
+	if err == nil && d.keyvals != nil && lvl == d.lvl && key == d.key {
+		if d.repeated == 0 {
+			d.first = now
+			run := d.run
+
+			d.timer = time.AfterFunc(d.interval, func() { d.expire(run) })
+		}
+
+		d.repeated++
+		d.last = now
+		d.mu.Unlock()
+
+		return
+	}
+
+	prevLvl, prevKvs, ok := d.summary()
+
+	// The keyvals are copied, as the caller may reuse the slice.
+	d.lvl, d.key, d.keyvals = lvl, key, nil
+	if err == nil {
+		d.keyvals = append([]interface{}{}, keyvals...)
+	}
+
+	d.mu.Unlock()
+
+	if ok {
+		logAt(d.log, prevLvl, prevKvs)
+	}
+
+	logAt(d.log, lvl, keyvals)
+}
+
+// expire flushes the repetitions at the end of the interval, unless they were
+// already flushed by a different entry.
+func (d *DeduplicatedLogger) expire(run uint64) {
+	d.mu.Lock()
+
+	if run != d.run {
+		d.mu.Unlock()
+
+		return
+	}
+
+	lvl, kvs, ok := d.summary()
+	d.mu.Unlock()
+
+	if ok {
+		logAt(d.log, lvl, kvs)
+	}
+}
+
+// summary returns the level and keyvals of the entry summarizing the
+// repetitions of the previous entry, and false if there are none.  The
+// repetitions are reset, and the timer of the interval stopped.  It must be
+// called with the lock held.
+func (d *DeduplicatedLogger) summary() (level.Level, []interface{}, bool) {
+	if d.repeated == 0 {
+		return 0, nil, false
+	}
+
+	kvs := make([]interface{}, 0, len(d.keyvals)+7) //nolint:gomnd // missing value and three more fields
+	kvs = append(kvs, d.keyvals...)
+
+	if len(kvs)%2 == 1 {
+		kvs = append(kvs, "missing")
+	}
+
+	kvs = append(kvs, RepeatedKey, d.repeated, RepeatSpanKey, d.last.Sub(d.first), CallerKey, noCaller)
+
+	d.timer.Stop()
+	d.timer = nil
+	d.repeated = 0
+	d.run++
+
+	return d.lvl, kvs, true
+}
+
+// logAt logs keyvals to l at the given level.
+func logAt(l Logger, lvl level.Level, keyvals []interface{}) {
+	switch lvl {
+	case level.Error:
+		l.Error(keyvals...)
+	case level.Info:
+		l.Info(keyvals...)
+	case level.Debug:
+		l.Debug(keyvals...)
+	case level.Trace:
+		l.Trace(keyvals...)
+	}
+}
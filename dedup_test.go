@@ -0,0 +1,150 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestDeduplicated(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithFilterLevel(level.All))
+
+	d := Deduplicated(l, time.Hour)
+
+	// Advance the clock by one second for each entry.
+	now := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	d.now = func() time.Time {
+		now = now.Add(time.Second)
+
+		return now
+	}
+
+	d.Error("msg", "backend down", "code", 503)
+	d.Error("msg", "backend down", "code", "503")
+	d.Error("msg", "backend down", "code", 503)
+	d.Info("msg", "backend down", "code", 503)
+	d.Info("msg", "retrying")
+	d.Info("msg", "retrying")
+	d.Debug("msg", "odd", "key")
+	d.Debug("msg", "odd", "key")
+
+	// End the interval without waiting for the timer.
+	d.expire(2)
+
+	d.Debug("msg", "odd", "key")
+	d.Trace("msg", "done")
+
+	want := `error {"msg":"backend down","code":"503"}
+error {"msg":"backend down","code":"503","repeated":"2","repeat_span":"1s"}
+info  {"msg":"backend down","code":"503"}
+info  {"msg":"retrying"}
+info  {"msg":"retrying","repeated":"1","repeat_span":"0s"}
+debug {"msg":"odd","key":"missing"}
+debug {"msg":"odd","key":"missing","repeated":"1","repeat_span":"0s"}
+debug {"msg":"odd","key":"missing","repeated":"1","repeat_span":"0s"}
+trace {"msg":"done"}
+`
+
+	if got := b.String(); got != want {
+		t.Errorf("Deduplicated: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDeduplicated_interval(t *testing.T) {
+	var (
+		mu sync.Mutex
+		b  bytes.Buffer
+	)
+
+	l := NewLeveledLogger(WithLogger(log.New(&lockedWriter{&mu, &b}, "", 0)))
+	d := Deduplicated(l, 10*time.Millisecond)
+
+	d.Error("msg", "backend down")
+	d.Error("msg", "backend down")
+	d.Error("msg", "backend down")
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		mu.Lock()
+		got := b.String()
+		mu.Unlock()
+
+		if strings.Contains(got, `"repeated":"2"`) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("repetitions not flushed: %s", got)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// flushingLogger flushes a DeduplicatedLogger when logging, like a sink
+// reporting its own errors through the logger it is part of.
+type flushingLogger struct {
+	Logger
+	d *DeduplicatedLogger
+}
+
+func (l *flushingLogger) Error(keyvals ...interface{}) {
+	l.d.Flush()
+	l.Logger.Error(keyvals...)
+}
+
+func TestDeduplicated_Close(t *testing.T) {
+	var b bytes.Buffer
+
+	fl := &flushingLogger{Logger: NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithCaller())}
+	d := Deduplicated(fl, time.Hour)
+	fl.d = d
+
+	d.now = func() time.Time { return time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC) }
+
+	d.Error("msg", "backend down")
+	d.Error("msg", "backend down")
+	d.Flush()
+	d.Flush()
+	d.Error("msg", "backend down")
+	d.Close()
+	d.Close()
+	d.Error("msg", "backend down")
+	d.Error("msg", "backend down")
+
+	want := `error {"caller":"CALLER","msg":"backend down"}
+error {"msg":"backend down","repeated":"1","repeat_span":"0s"}
+error {"msg":"backend down","repeated":"1","repeat_span":"0s"}
+error {"caller":"CALLER","msg":"backend down"}
+error {"caller":"CALLER","msg":"backend down"}
+`
+
+	got := regexp.MustCompile(`"caller":"[^"]*/dedup_test.go:\d+"`).ReplaceAllString(b.String(), `"caller":"CALLER"`)
+	if got != want {
+		t.Errorf("Deduplicated: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// Entry is a single log message handed to an Encoder.
+type Entry struct {
+	// Time is the time the message was logged at.
+	Time time.Time
+
+	// Level is the level the message was logged at.
+	Level level.Level
+
+	// Keyvals are the alternating keys and values passed to the logging call.
+	Keyvals []interface{}
+
+	// Keys are the key names configured with WithKeyNames.  Empty names stand
+	// for their default from DefaultKeyNames.
+	Keys KeyNames
+}
+
+// Encoder converts log entries into the bytes written by a LeveledLogger. Each
+// call to Encode produces a single log line; a trailing newline is optional and
+// is added by the LeveledLogger if missing.
+//
+// Encoders are used concurrently by the LeveledLogger and must be safe for
+// concurrent use.
+type Encoder interface {
+	Encode(e *Entry) ([]byte, error)
+}
+
+// JSONEncoder is the default Encoder of a LeveledLogger that does not write to
+// a terminal.  It writes the level as a padded text prefix followed by a JSON
+// object holding the keyvals in the order they were given:
+//
+//	info  {"msg":"server started","port":"8080"}
+//
+// Values that do not implement json.Marshaler or encoding.TextMarshaler are
+// formatted to strings, unless the WithNativeTypes option is used.
+type JSONEncoder struct {
+	nativeTypes bool
+	levelKey    string
+}
+
+// NewJSONEncoder returns a new JSONEncoder.  Its defaults can be changed by
+// providing JSONOptions.
+func NewJSONEncoder(opts ...JSONOption) *JSONEncoder {
+	enc := JSONEncoder{}
+
+	for _, opt := range opts {
+		opt(&enc)
+	}
+
+	return &enc
+}
+
+type JSONOption func(*JSONEncoder)
+
+// WithNativeTypes returns a JSONOption that configures the JSONEncoder to write
+// values that have a native JSON representation as such, instead of formatting
+// them to strings.  For example, "count", 12 is written as "count":12 rather
+// than "count":"12", and a slice is written as a JSON array.  Values
+// implementing error or fmt.Stringer are still written as strings.
+func WithNativeTypes() JSONOption {
+	return func(enc *JSONEncoder) {
+		enc.nativeTypes = true
+	}
+}
+
+// WithLevelKey returns a JSONOption that configures the JSONEncoder to write
+// the level as the first field of the JSON object, with the given key, rather
+// than as a text prefix:
+//
+//	{"level":"info","msg":"server started","port":"8080"}
+//
+// Keyvals with the same key are prefixed with ReservedKeyPrefix.
+func WithLevelKey(key string) JSONOption {
+	return func(enc *JSONEncoder) {
+		enc.levelKey = key
+	}
+}
+
+// Encode implements the Encoder interface.
+func (enc *JSONEncoder) Encode(e *Entry) ([]byte, error) {
+	fromKeyvals := logmap.FromKeyvals
+	if enc.nativeTypes {
+		fromKeyvals = logmap.FromKeyvalsTyped
+	}
+
+	ms := fromKeyvals(e.Keyvals...)
+
+	if enc.levelKey != "" {
+		renameReservedItem(ms, enc.levelKey)
+		ms = append(logmap.MapSlice{{Key: enc.levelKey, Value: e.Level.String()}}, ms...)
+	}
+
+	line, err := ms.JSONString()
+	if err != nil {
+		return nil, err
+	}
+
+	if enc.levelKey != "" {
+		return []byte(line), nil
+	}
+
+	return []byte(fmt.Sprintf("%-5s %s", e.Level, line)), nil
+}
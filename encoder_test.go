@@ -0,0 +1,140 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	enc := NewJSONEncoder()
+
+	tests := []struct {
+		entry Entry
+		want  string
+	}{
+		{Entry{Level: level.Info}, "info  {}\n"},
+		{Entry{Level: level.Error, Keyvals: []interface{}{"msg", "hello", "n", 1}}, `error {"msg":"hello","n":"1"}` + "\n"},
+		{Entry{Level: level.Trace, Keyvals: []interface{}{"msg"}}, `trace {"msg":"missing"}` + "\n"},
+	}
+
+	for _, tc := range tests {
+		got, err := enc.Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("Encode(%v): got %q, want %q", tc.entry, got, tc.want)
+		}
+	}
+
+	if _, err := enc.Encode(&Entry{Level: level.Info, Keyvals: []interface{}{"msg", &failingJSONMarshaler{}}}); err == nil {
+		t.Error("expected an error from a failing json.Marshaler")
+	}
+}
+
+func TestJSONEncoder_WithNativeTypes(t *testing.T) {
+	enc := NewJSONEncoder(WithNativeTypes())
+
+	got, err := enc.Encode(&Entry{Level: level.Info, Keyvals: []interface{}{
+		"msg", "typed", "count", 12, "ratio", 0.5, "ok", true, "none", nil,
+		"list", []int{0, 1, 2, 3}, "map", map[string]int{"a": 1}, "err", errInvalidValue,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `info  {"msg":"typed","count":12,"ratio":0.5,"ok":true,"none":null,` +
+		`"list":[0,1,2,3],"map":{"a":1},"err":"invalid value"}` + "\n"
+
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// pipeEncoder writes the level and the keyvals separated by pipes, or fails
+// for keyvals containing a failingJSONMarshaler.
+type pipeEncoder struct{}
+
+func (pipeEncoder) Encode(e *Entry) ([]byte, error) {
+	parts := []string{e.Level.String()}
+
+	for _, kv := range e.Keyvals {
+		if _, ok := kv.(*failingJSONMarshaler); ok {
+			return nil, errInvalidValue
+		}
+
+		parts = append(parts, fmt.Sprint(kv))
+	}
+
+	return []byte(strings.Join(parts, "|")), nil
+}
+
+func TestLeveledLogger_WithEncoder(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithEncoder(pipeEncoder{}))
+
+	l.Info("msg", "hello", "n", 1)
+	l.Error("msg", &failingJSONMarshaler{})
+
+	want := "info|msg|hello|n|1\nerror|msg|logging failure|error|invalid value\n"
+
+	if got := b.String(); got != want {
+		t.Errorf("WithEncoder: got %q, want %q", got, want)
+	}
+}
+
+// brokenEncoder fails to encode any entry.
+type brokenEncoder struct{}
+
+func (brokenEncoder) Encode(e *Entry) ([]byte, error) {
+	return nil, errInvalidValue
+}
+
+func TestLeveledLogger_WithEncoder_failure(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithEncoder(brokenEncoder{}))
+
+	l.Info("msg", "hello")
+
+	want := `error {"msg":"logging failure","error":"invalid value"}` + "\n"
+
+	if got := b.String(); got != want {
+		t.Errorf("WithEncoder failure: got %q, want %q", got, want)
+	}
+
+	b.Reset()
+
+	l = NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithEncoder(brokenEncoder{}), WithKeyNames(KeyNames{Message: "message"}))
+
+	l.Info("msg", "hello")
+
+	want = `error {"message":"logging failure","error":"invalid value"}` + "\n"
+
+	if got := b.String(); got != want {
+		t.Errorf("WithEncoder failure with key names: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,321 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package httpbatch sends messages to HTTP endpoints in batches.  It implements
+// the queueing, batching and retries shared by the writers of the loki and otlp
+// packages, which provide the format of the batches and the requests.
+package httpbatch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Batch holds the messages sent in a request.
+type Batch interface {
+	// Add adds a message to the batch.
+	Add(msg interface{})
+
+	// Count returns the number of messages of the batch.
+	Count() int
+
+	// Full reports whether the batch must be sent without waiting for more
+	// messages.
+	Full() bool
+
+	// Encode returns the body of the request sending the batch.
+	Encode() ([]byte, error)
+}
+
+// PostFunc sends the body of a batch, and reports whether the request may be
+// retried if it failed.
+type PostFunc func(ctx context.Context, body []byte) (retryable bool, err error)
+
+// Config holds the settings of a Writer.
+type Config struct {
+	// BatchWait is the maximum duration a batch waits for more messages.
+	BatchWait time.Duration
+
+	// MaxRetries is the number of retries of failed requests, whose delays
+	// double after each retry from MinBackoff up to MaxBackoff.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// QueueSize is the number of batches waiting to be sent before Add waits.
+	QueueSize int
+
+	// CloseTimeout bounds the time Close waits for the queued batches to be
+	// sent.
+	CloseTimeout time.Duration
+
+	// ErrClosed is the error returned by Add and Close after Close.
+	ErrClosed error
+}
+
+// Writer groups messages into batches, which are sent by a separate
+// goroutine when they are full or after the batch wait duration.  Adding
+// messages waits when the queue of batches is full, so that messages are not
+// lost while the server is slow.  A Writer is safe for concurrent use.
+type Writer struct {
+	cfg      Config
+	newBatch func() Batch
+	post     PostFunc
+
+	stopped chan struct{}
+	dropped uint64
+
+	// ctx is canceled when Close gives up waiting, which interrupts the
+	// requests, the delays between retries and the writers waiting for room
+	// in the queue.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu protects the fields below.  It is never held while waiting for the
+	// server: writers waiting for room in the queue wait on cond, which
+	// releases mu.
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	queue  []Batch
+	batch  Batch
+	timer  *time.Timer
+}
+
+// New returns a new Writer sending the batches created by newBatch with post.
+func New(cfg Config, newBatch func() Batch, post PostFunc) *Writer {
+	w := &Writer{
+		cfg:      cfg,
+		newBatch: newBatch,
+		post:     post,
+		stopped:  make(chan struct{}),
+	}
+
+	w.cond = sync.NewCond(&w.mu)
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+
+	go w.run()
+
+	return w
+}
+
+// Add adds the messages to the current batch.  When a batch is full and the
+// queue already holds the configured number of batches, Add waits until the
+// queue has room, or Close gives up sending them.
+func (w *Writer) Add(msgs ...interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return w.cfg.ErrClosed
+	}
+
+	for _, msg := range msgs {
+		if w.batch == nil {
+			w.batch = w.newBatch()
+			w.timer = time.AfterFunc(w.cfg.BatchWait, w.flushTimer(w.batch))
+		}
+
+		w.batch.Add(msg)
+
+		if w.batch.Full() {
+			w.flush()
+
+			for len(w.queue) > w.cfg.QueueSize && w.ctx.Err() == nil {
+				w.cond.Wait()
+			}
+		}
+	}
+
+	return nil
+}
+
+// Dropped returns the number of messages dropped because their batch could
+// not be sent.
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close sends the current batch and waits until the queued batches are sent or
+// dropped.  After the close timeout, the pending requests and retries are
+// canceled, and the remaining batches are dropped.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+
+		return w.cfg.ErrClosed
+	}
+
+	deadline := time.AfterFunc(w.cfg.CloseTimeout, w.stop)
+	defer deadline.Stop()
+
+	w.flush()
+	w.closed = true
+	w.cond.Broadcast()
+	w.mu.Unlock()
+
+	<-w.stopped
+	w.stop()
+
+	return nil
+}
+
+// stop cancels the pending requests, and wakes up the writers waiting for room
+// in the queue.
+func (w *Writer) stop() {
+	w.cancel()
+
+	w.mu.Lock()
+	w.cond.Broadcast()
+	w.mu.Unlock()
+}
+
+// flushTimer returns the function flushing the batch after the batch wait
+// duration, unless it was already flushed.
+func (w *Writer) flushTimer(b Batch) func() {
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if w.batch == b && !w.closed {
+			w.flush()
+		}
+	}
+}
+
+// flush queues the current batch.  It must be called with the lock held, and
+// does not wait for room in the queue, which is up to the callers.
+func (w *Writer) flush() {
+	if w.batch == nil {
+		return
+	}
+
+	w.timer.Stop()
+	w.queue = append(w.queue, w.batch)
+	w.batch = nil
+	w.cond.Broadcast()
+}
+
+// next returns the next queued batch, waiting until there is one, or false if
+// the queue is empty and the Writer is closed.
+func (w *Writer) next() (Batch, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for len(w.queue) == 0 && !w.closed {
+		w.cond.Wait()
+	}
+
+	if len(w.queue) == 0 {
+		return nil, false
+	}
+
+	b := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	w.cond.Broadcast()
+
+	return b, true
+}
+
+// run sends the queued batches until the Writer is closed.
+func (w *Writer) run() {
+	defer close(w.stopped)
+
+	for {
+		b, ok := w.next()
+		if !ok {
+			return
+		}
+
+		if err := w.send(b); err != nil {
+			atomic.AddUint64(&w.dropped, uint64(b.Count()))
+		}
+	}
+}
+
+// send sends the batch, retrying the requests that may be retried.
+func (w *Writer) send(b Batch) error {
+	body, err := b.Encode()
+	if err != nil {
+		return err
+	}
+
+	backoff := w.cfg.MinBackoff
+
+	for retry := 0; ; retry++ {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		retryable, err := w.post(w.ctx, body)
+		if err == nil || !retryable || retry >= w.cfg.MaxRetries {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+
+			return w.ctx.Err()
+		}
+
+		if backoff *= 2; backoff > w.cfg.MaxBackoff {
+			backoff = w.cfg.MaxBackoff
+		}
+	}
+}
+
+// Post sends the body to the URL with the given headers, and returns the status
+// code of the response, whose body is discarded.
+func Post(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// StatusError returns the error of a request that failed with the status code.
+func StatusError(prefix string, code int) error {
+	return fmt.Errorf("%s: %d %s", prefix, code, http.StatusText(code))
+}
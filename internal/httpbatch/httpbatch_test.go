@@ -0,0 +1,228 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package httpbatch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errClosed = errors.New("closed")
+
+// testBatch holds strings, sent joined by spaces.
+type testBatch struct {
+	msgs  []string
+	limit int
+}
+
+func (b *testBatch) Add(msg interface{}) { b.msgs = append(b.msgs, msg.(string)) }
+func (b *testBatch) Count() int          { return len(b.msgs) }
+func (b *testBatch) Full() bool          { return len(b.msgs) >= b.limit }
+
+func (b *testBatch) Encode() ([]byte, error) {
+	return []byte(strings.Join(b.msgs, " ")), nil
+}
+
+// testServer records the bodies it receives, and fails with the given errors
+// first.
+type testServer struct {
+	mu     sync.Mutex
+	bodies []string
+	errs   []error
+}
+
+func (s *testServer) post(ctx context.Context, body []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+
+		return true, err
+	}
+
+	s.bodies = append(s.bodies, string(body))
+
+	return false, nil
+}
+
+func newTestWriter(cfg Config, s *testServer, limit int) (*Writer, *[]*testBatch) {
+	var batches []*testBatch
+
+	cfg.ErrClosed = errClosed
+
+	w := New(cfg, func() Batch {
+		b := &testBatch{limit: limit}
+		batches = append(batches, b)
+
+		return b
+	}, s.post)
+
+	return w, &batches
+}
+
+func TestWriter(t *testing.T) {
+	s := &testServer{errs: []error{errors.New("unavailable")}}
+	w, _ := newTestWriter(Config{BatchWait: time.Hour, MaxRetries: 1, QueueSize: 1, CloseTimeout: time.Minute}, s, 2)
+
+	if err := w.Add("a", "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(s.bodies, "|"); got != "a b|c" || w.Dropped() != 0 {
+		t.Errorf("got bodies %q and %d dropped", got, w.Dropped())
+	}
+
+	if err := w.Add("d"); err != errClosed {
+		t.Errorf("Add after Close: got %v, want %v", err, errClosed)
+	}
+
+	if err := w.Close(); err != errClosed {
+		t.Errorf("second Close: got %v, want %v", err, errClosed)
+	}
+}
+
+func TestWriter_staleTimer(t *testing.T) {
+	s := &testServer{}
+	w, batches := newTestWriter(Config{BatchWait: time.Hour, QueueSize: 2, CloseTimeout: time.Minute}, s, 2)
+
+	if err := w.Add("a", "b", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The timer of the first batch, which was flushed when full, fires late.
+	w.flushTimer((*batches)[0])()
+
+	w.mu.Lock()
+	pending := w.batch
+	w.mu.Unlock()
+
+	if pending == nil {
+		t.Error("stale timer flushed the current batch")
+	}
+
+	w.flushTimer((*batches)[1])()
+
+	w.mu.Lock()
+	pending = w.batch
+	w.mu.Unlock()
+
+	if pending != nil {
+		t.Error("timer did not flush its batch")
+	}
+
+	w.Close()
+
+	if got := strings.Join(s.bodies, "|"); got != "a b|c" {
+		t.Errorf("got bodies %q", got)
+	}
+}
+
+func TestWriter_closeTimeout(t *testing.T) {
+	s := &testServer{errs: []error{errors.New("unavailable")}}
+	w, _ := newTestWriter(Config{BatchWait: time.Hour, MaxRetries: 10, MinBackoff: time.Hour, MaxBackoff: time.Hour,
+		QueueSize: 1, CloseTimeout: 10 * time.Millisecond}, s, 10)
+
+	if err := w.Add("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		w.Close()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close did not interrupt the backoff")
+	}
+
+	if w.Dropped() != 2 || len(s.bodies) != 0 {
+		t.Errorf("got %d dropped and bodies %q", w.Dropped(), s.bodies)
+	}
+}
+
+func TestWriter_hangingServer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+
+	defer srv.Close()
+	defer close(release)
+
+	post := func(ctx context.Context, body []byte) (bool, error) {
+		_, err := Post(ctx, http.DefaultClient, srv.URL, http.Header{}, body)
+
+		return true, err
+	}
+
+	w := New(Config{BatchWait: time.Hour, QueueSize: 1, CloseTimeout: 50 * time.Millisecond, ErrClosed: errClosed},
+		func() Batch { return &testBatch{limit: 1} }, post)
+
+	// The first batch is sent and hangs, the second one is queued, and the
+	// writer waits for room in the queue after the third one.
+	added := make(chan struct{})
+
+	go func() {
+		defer close(added)
+
+		for i := 0; i < 5; i++ {
+			if err := w.Add("msg"); err != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+		w.Close()
+	}()
+
+	for name, ch := range map[string]chan struct{}{"Close": closed, "Add": added} {
+		select {
+		case <-ch:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s did not return", name)
+		}
+	}
+
+	if w.Dropped() == 0 {
+		t.Error("expected dropped messages")
+	}
+}
@@ -21,6 +21,8 @@ import (
 	"encoding"
 	"encoding/json"
 	"fmt"
+	"math"
+	"reflect"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -32,6 +34,24 @@ import (
 //
 // A final value "missing" is inserted if an odd number of values are passed to FromKeyvals.
 func FromKeyvals(keyvals ...interface{}) MapSlice {
+	return fromKeyvals(stringValue, keyvals)
+}
+
+// FromKeyvalsTyped is like FromKeyvals, but preserves values that have a native JSON
+// representation instead of formatting them to strings. The value of each key is chosen as
+// follows:
+//
+//   - values implementing json.Marshaler or encoding.TextMarshaler are kept, as by FromKeyvals.
+//   - values implementing error or fmt.Stringer are formatted with fmt.Sprint.
+//   - nil, booleans, strings, integers and finite floating point numbers are kept.
+//   - complex numbers, channels and functions are formatted with fmt.Sprint.
+//   - all other values, such as slices, maps and structs, are replaced by their JSON encoding,
+//     unless that encoding fails, in which case they are formatted with fmt.Sprint.
+func FromKeyvalsTyped(keyvals ...interface{}) MapSlice {
+	return fromKeyvals(typedValue, keyvals)
+}
+
+func fromKeyvals(value func(interface{}) interface{}, keyvals []interface{}) MapSlice {
 	n := (len(keyvals) + 1) / 2 // +1 to handle case when len is odd
 	m := make(MapSlice, 0, n)
 
@@ -43,24 +63,60 @@ func FromKeyvals(keyvals ...interface{}) MapSlice {
 			v = keyvals[i+1]
 		}
 
-		key := fmt.Sprint(k)
-
-		// If v implements json.Marshaler or encoding.TextMarshaler, we
-		// give that a priority over fmt.Sprint
-		var val interface{}
-		switch v.(type) {
-		case json.Marshaler:
-			val = v
-		case encoding.TextMarshaler:
-			val = v
-		default:
-			val = fmt.Sprint(v)
+		m = append(m, MapItem{Key: fmt.Sprint(k), Value: value(v)})
+	}
+
+	return m
+}
+
+// stringValue gives json.Marshaler or encoding.TextMarshaler values priority over fmt.Sprint.
+func stringValue(v interface{}) interface{} {
+	switch v.(type) {
+	case json.Marshaler:
+		return v
+	case encoding.TextMarshaler:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// typedValue implements the rules documented by FromKeyvalsTyped.
+func typedValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return v
+	case error, fmt.Stringer:
+		return fmt.Sprint(v)
+	case nil, bool, string,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr:
+		return v
+	case float32:
+		if math.IsNaN(float64(val)) || math.IsInf(float64(val), 0) {
+			return fmt.Sprint(v)
+		}
+
+		return v
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Sprint(v)
 		}
 
-		m = append(m, MapItem{Key: key, Value: val})
+		return v
 	}
 
-	return m
+	switch reflect.TypeOf(v).Kind() { //nolint:exhaustive // all other kinds are JSON encoded
+	case reflect.Complex64, reflect.Complex128, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return fmt.Sprint(v)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return json.RawMessage(b)
 }
 
 type MapItem struct {
@@ -191,3 +247,36 @@ func StringFromValue(v interface{}) string {
 		return fmt.Sprint(v)
 	}
 }
+
+// TextFromValue creates a string from the value for text based formats. Values implementing
+// json.Marshaler are formatted as compact JSON, unquoted if it is a JSON string (e.g. a time.Time
+// is formatted as 2021-03-04T00:00:00Z), values implementing encoding.TextMarshaler are formatted
+// with MarshalText, and all others with fmt.Sprint. Unlike StringFromValue, it returns marshaling
+// errors instead of panicking.
+func TextFromValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case json.Marshaler:
+		valBytes, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+
+		var s string
+		if len(valBytes) > 0 && valBytes[0] == '"' && json.Unmarshal(valBytes, &s) == nil {
+			return s, nil
+		}
+
+		return string(valBytes), nil
+
+	case encoding.TextMarshaler:
+		valBytes, err := val.MarshalText()
+		if err != nil {
+			return "", err
+		}
+
+		return string(valBytes), nil
+
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
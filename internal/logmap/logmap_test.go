@@ -17,9 +17,12 @@
 package logmap
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -71,6 +74,73 @@ func TestFromKeyvals(t *testing.T) {
 	}
 }
 
+func TestFromKeyvalsTyped(t *testing.T) {
+	t.Parallel()
+
+	type point struct {
+		X, Y int
+	}
+
+	type count int
+
+	tests := map[string]struct {
+		in  []interface{}
+		out MapSlice
+	}{
+		"empty": {},
+		"missing": {
+			[]interface{}{"msg"},
+			MapSlice{MapItem{"msg", "missing"}},
+		},
+		"scalars": {
+			[]interface{}{"i", 4, "u", uint8(5), "f", 2.5, "b", true, "n", nil, "s", "str"},
+			MapSlice{
+				MapItem{"i", 4}, MapItem{"u", uint8(5)}, MapItem{"f", 2.5},
+				MapItem{"b", true}, MapItem{"n", nil}, MapItem{"s", "str"},
+			},
+		},
+		"non_finite": {
+			[]interface{}{"nan", math.NaN(), "inf", float32(math.Inf(1))},
+			MapSlice{MapItem{"nan", "NaN"}, MapItem{"inf", "+Inf"}},
+		},
+		"composites": {
+			[]interface{}{"slice", []int{0, 1}, "map", map[string]bool{"a": true}, "struct", point{1, 2}, "named", count(3)},
+			MapSlice{
+				MapItem{"slice", json.RawMessage("[0,1]")},
+				MapItem{"map", json.RawMessage(`{"a":true}`)},
+				MapItem{"struct", json.RawMessage(`{"X":1,"Y":2}`)},
+				MapItem{"named", json.RawMessage("3")},
+			},
+		},
+		"formatted": {
+			[]interface{}{"err", errOnPurpose, "dur", time.Second, "complex", 1 + 2i, "chan", []chan int{nil}},
+			MapSlice{
+				MapItem{"err", "this error is on purpose"}, MapItem{"dur", "1s"},
+				MapItem{"complex", "(1+2i)"}, MapItem{"chan", "[<nil>]"},
+			},
+		},
+		"marshalers": {
+			[]interface{}{"text", newTestMarshaler(), "json", newTestJSONMarshaler()},
+			MapSlice{MapItem{"text", newTestMarshaler()}, MapItem{"json", newTestJSONMarshaler()}},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			exp := FromKeyvalsTyped(tc.in...)
+
+			diff := cmp.Diff(tc.out, exp, cmpopts.EquateEmpty())
+			if diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 const nilStr = "<nil>"
 
 func TestMapSlice_UnmarshalJSON(t *testing.T) {
@@ -286,6 +356,47 @@ func TestMapSlice_StringFromValue_panic(t *testing.T) {
 	}
 }
 
+func TestTextFromValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in  interface{}
+		out string
+		err string
+	}{
+		"string":       {"Hello.", "Hello.", nilStr},
+		"int":          {42, "42", nilStr},
+		"json":         {newTestJSONMarshaler(), `{"fancy JSON":6}`, nilStr},
+		"json_string":  {time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), "2021-03-04T00:00:00Z", nilStr},
+		"text":         {newTestMarshaler(), "my text: 5", nilStr},
+		"json_failure": {newFailingJSONMarshaler(), "", "json: error calling MarshalJSON for type *logmap.failingJSONMarshaler: this error is on purpose"},
+		"text_failure": {newFailingTextMarshaler(), "", "this error is on purpose"},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := TextFromValue(tc.in)
+
+			errStr := nilStr
+			if err != nil {
+				errStr = err.Error()
+			}
+
+			if diff := cmp.Diff(tc.err, errStr); diff != "" {
+				t.Errorf("unexpected error (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.out, out); diff != "" {
+				t.Errorf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 // testMarshaler is a type that implements the encoding.TextMarshaler interface, for testing.
 type testMarshaler struct {
 	A int
@@ -0,0 +1,123 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package netconn manages the connections of the writers sending messages to
+// log servers over the network.  It implements the reconnection shared by the
+// writers of the syslog and gelf packages, which provide the connections and
+// the format of the messages.
+package netconn
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// DialTimeout bounds the time spent connecting to a server.
+const DialTimeout = 10 * time.Second
+
+// errNotConnected is returned when sending a message after a failed
+// reconnection.
+var errNotConnected = errors.New("not connected")
+
+// DialFunc connects to the server.
+type DialFunc func() (net.Conn, error)
+
+// SendFunc sends a message over the connection.
+type SendFunc func(conn net.Conn) error
+
+// Conn is a connection to a server.  If sending a message fails, for instance
+// because the server restarted, Conn reconnects and sends the message again
+// once.  A Conn is safe for concurrent use.
+type Conn struct {
+	dial      DialFunc
+	errClosed error
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// Dial returns a new Conn connected with dial.  Send and Close return
+// errClosed after Close.
+func Dial(dial DialFunc, errClosed error) (*Conn, error) {
+	conn, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{dial: dial, errClosed: errClosed, conn: conn}, nil
+}
+
+// Send sends a message with send, reconnecting and calling send again once if
+// it fails.
+func (c *Conn) Send(send SendFunc) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return c.errClosed
+	}
+
+	err := c.send(send)
+	if err != nil {
+		if c.conn != nil {
+			c.conn.Close()
+			c.conn = nil
+		}
+
+		var conn net.Conn
+
+		if conn, err = c.dial(); err == nil {
+			c.conn = conn
+			err = c.send(send)
+		}
+	}
+
+	return err
+}
+
+// Close closes the connection.  The Conn cannot be used afterwards.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return c.errClosed
+	}
+
+	c.closed = true
+
+	if c.conn == nil {
+		return nil
+	}
+
+	err := c.conn.Close()
+	c.conn = nil
+
+	return err
+}
+
+// send sends a message over the current connection.  It must be called with
+// the lock held.
+func (c *Conn) send(send SendFunc) error {
+	if c.conn == nil {
+		return errNotConnected
+	}
+
+	return send(c.conn)
+}
@@ -0,0 +1,127 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package netconn
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+var errClosed = errors.New("closed")
+
+// testDialer dials pipes, whose other ends it closes, and fails after the
+// given number of dials.
+type testDialer struct {
+	dials int
+	max   int
+}
+
+func (d *testDialer) dial() (net.Conn, error) {
+	if d.dials >= d.max {
+		return nil, errors.New("refused")
+	}
+
+	d.dials++
+
+	c1, c2 := net.Pipe()
+	c2.Close()
+
+	return c1, nil
+}
+
+func TestConn_reconnect(t *testing.T) {
+	d := &testDialer{max: 2}
+
+	c, err := Dial(d.dial, errClosed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []net.Conn
+
+	send := func(conn net.Conn) error {
+		sent = append(sent, conn)
+
+		if len(sent) == 1 {
+			return errors.New("broken")
+		}
+
+		return nil
+	}
+
+	if err := c.Send(send); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if d.dials != 2 || len(sent) != 2 || sent[0] == sent[1] {
+		t.Errorf("got %d dials and %d sends, want a send over a new connection", d.dials, len(sent))
+	}
+
+	fail := func(net.Conn) error { return errors.New("broken") }
+
+	if err := c.Send(fail); err == nil {
+		t.Error("expected an error when reconnecting fails")
+	}
+
+	if err := c.Send(send); err == nil || err == errClosed {
+		t.Errorf("got %v, want an error when not connected", err)
+	}
+
+	d.max = 3
+
+	if err := c.Send(send); err != nil {
+		t.Errorf("unexpected error after reconnecting: %v", err)
+	}
+}
+
+func TestConn_Close(t *testing.T) {
+	d := &testDialer{max: 2}
+
+	c, err := Dial(d.dial, errClosed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(net.Conn) error {
+		t.Error("sent after Close")
+
+		return nil
+	}
+
+	if err := c.Send(send); err != errClosed {
+		t.Errorf("Send after Close: got %v, want %v", err, errClosed)
+	}
+
+	if err := c.Close(); err != errClosed {
+		t.Errorf("Close after Close: got %v, want %v", err, errClosed)
+	}
+
+	if d.dials != 1 {
+		t.Errorf("got %d dials, want 1", d.dials)
+	}
+}
+
+func TestDial_error(t *testing.T) {
+	if _, err := Dial((&testDialer{}).dial, errClosed); err == nil {
+		t.Error("expected a dial error")
+	}
+}
@@ -0,0 +1,188 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import "github.com/cobaltspeech/log/internal/logmap"
+
+// MessageKey is the key callers use for the message of log entries.
+const MessageKey = "msg"
+
+// LevelKey is the default key of the level written as a field by Encoders such
+// as the LogfmtEncoder.  See WithKeyNames.
+const LevelKey = "level"
+
+// ReservedKeyPrefix is prepended to the keys of keyvals that collide with the
+// key of a field added by the LeveledLogger or its Encoder.
+//
+// The fields added by the LeveledLogger (the timestamp, caller and stack
+// trace) and by the Encoder (e.g. the level field of WithLevelKey) always keep
+// their key.  If a message has keyvals with the same key, they are kept as
+// well, but their key is prefixed with ReservedKeyPrefix, e.g.
+//
+//	l.Info("msg", "request done", "caller", "api-client")
+//
+// logged with WithCaller is written as
+//
+//	info  {"caller":"server/api.go:42","msg":"request done","fields.caller":"api-client"}
+//
+// so that no field is lost and parsers never see duplicate keys.
+const ReservedKeyPrefix = "fields."
+
+// KeyNames are the keys of the fields written by a LeveledLogger.
+type KeyNames struct {
+	// Message replaces the MessageKey of the keyvals passed to the logging
+	// methods, e.g. "message".
+	Message string
+
+	// Time is the key of the timestamp when the TimestampField placement is
+	// used.
+	Time string
+
+	// Caller is the key of the field added by WithCaller.
+	Caller string
+
+	// Stacktrace is the key of the field added by WithStacktrace.
+	Stacktrace string
+
+	// Level is the key of the level written by Encoders that write it as a
+	// field, such as the LogfmtEncoder.
+	Level string
+}
+
+// DefaultKeyNames are the keys used by a LeveledLogger unless changed with
+// WithKeyNames.
+var DefaultKeyNames = KeyNames{
+	Message:    MessageKey,
+	Time:       TimeKey,
+	Caller:     CallerKey,
+	Stacktrace: StacktraceKey,
+	Level:      LevelKey,
+}
+
+// WithKeyNames returns an Option that configures the keys of the fields
+// written by the LeveledLogger.  Empty names keep their default from
+// DefaultKeyNames.  See ReservedKeyPrefix for how keyvals whose key collides
+// with these names are written.
+//
+// The names are passed to the Encoder with each Entry.  The level key is used
+// by the Encoders writing the level as a field, except the JSONEncoder, whose
+// level field is keyed as set with WithLevelKey.
+func WithKeyNames(keys KeyNames) Option {
+	return func(l *LeveledLogger) {
+		if keys.Message != "" {
+			l.keys.Message = keys.Message
+		}
+
+		if keys.Time != "" {
+			l.keys.Time = keys.Time
+		}
+
+		if keys.Caller != "" {
+			l.keys.Caller = keys.Caller
+		}
+
+		if keys.Stacktrace != "" {
+			l.keys.Stacktrace = keys.Stacktrace
+		}
+
+		if keys.Level != "" {
+			l.keys.Level = keys.Level
+		}
+	}
+}
+
+// withDefaults returns the names with the empty ones replaced by their default
+// from DefaultKeyNames, for Entries that were not created by a LeveledLogger.
+func (k KeyNames) withDefaults() KeyNames {
+	if k.Message == "" {
+		k.Message = DefaultKeyNames.Message
+	}
+
+	if k.Time == "" {
+		k.Time = DefaultKeyNames.Time
+	}
+
+	if k.Caller == "" {
+		k.Caller = DefaultKeyNames.Caller
+	}
+
+	if k.Stacktrace == "" {
+		k.Stacktrace = DefaultKeyNames.Stacktrace
+	}
+
+	if k.Level == "" {
+		k.Level = DefaultKeyNames.Level
+	}
+
+	return k
+}
+
+// withFields returns the fields added by the logger followed by keyvals.  The
+// MessageKey of keyvals is renamed to the configured message key, and keys
+// colliding with the fields or the message key are prefixed with
+// ReservedKeyPrefix.
+func (l *LeveledLogger) withFields(fields, keyvals []interface{}) []interface{} {
+	if len(fields) == 0 && l.keys.Message == MessageKey {
+		return keyvals
+	}
+
+	out := make([]interface{}, 0, len(fields)+len(keyvals))
+	out = append(out, fields...)
+
+	for i, kv := range keyvals {
+		if i%2 == 0 {
+			kv = l.renameKey(kv, fields)
+		}
+
+		out = append(out, kv)
+	}
+
+	return out
+}
+
+func (l *LeveledLogger) renameKey(k interface{}, fields []interface{}) interface{} {
+	s, ok := k.(string)
+	if !ok {
+		return k
+	}
+
+	if s == MessageKey {
+		return l.keys.Message
+	}
+
+	if s == l.keys.Message {
+		return ReservedKeyPrefix + s
+	}
+
+	for i := 0; i < len(fields); i += 2 {
+		if fields[i] == s {
+			return ReservedKeyPrefix + s
+		}
+	}
+
+	return k
+}
+
+// renameReservedItem prefixes the key of the items of ms that collide with a
+// field added by an Encoder with ReservedKeyPrefix.
+func renameReservedItem(ms logmap.MapSlice, key string) {
+	for i := range ms {
+		if ms[i].Key == key {
+			ms[i].Key = ReservedKeyPrefix + key
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWithKeyNames(t *testing.T) {
+	keys := KeyNames{Message: "message", Time: "timestamp", Caller: "source"}
+
+	tests := map[string]struct {
+		opts    []Option
+		keyvals []interface{}
+		want    string
+	}{
+		"defaults": {
+			[]Option{WithKeyNames(KeyNames{})},
+			[]interface{}{"msg", "hello"},
+			`info  {"msg":"hello"}`,
+		},
+		"message": {
+			[]Option{WithKeyNames(keys)},
+			[]interface{}{"msg", "hello", "message", "other"},
+			`info  {"message":"hello","fields.message":"other"}`,
+		},
+		"time": {
+			[]Option{WithKeyNames(keys), WithTimestamp(TimestampField)},
+			[]interface{}{"msg", "hello", "timestamp", "yesterday", "ts", "today"},
+			`info  {"timestamp":"2021-03-04T10:06:07.890123456Z","message":"hello","fields.timestamp":"yesterday","ts":"today"}`,
+		},
+		"caller": {
+			[]Option{WithKeyNames(keys), WithCaller()},
+			[]interface{}{"msg", "hello", "source", "api-client"},
+			`info  {"source":"unknown","message":"hello","fields.source":"api-client"}`,
+		},
+		"level_field": {
+			[]Option{WithKeyNames(keys), WithEncoder(NewJSONEncoder(WithLevelKey("severity")))},
+			[]interface{}{"msg", "hello", "severity", "high"},
+			`{"severity":"info","message":"hello","fields.severity":"high"}`,
+		},
+		"logfmt_level": {
+			[]Option{WithEncoder(NewLogfmtEncoder())},
+			[]interface{}{"msg", "hello", "level", "high"},
+			`level=info msg=hello fields.level=high`,
+		},
+		"logfmt_level_key": {
+			[]Option{WithKeyNames(KeyNames{Level: "severity"}), WithEncoder(NewLogfmtEncoder())},
+			[]interface{}{"msg", "hello", "severity", "high", "level", "low"},
+			`severity=info msg=hello fields.severity=high level=low`,
+		},
+		"console_message": {
+			[]Option{WithKeyNames(keys), WithEncoder(NewConsoleEncoder(false))},
+			[]interface{}{"msg", "hello", "n", 1},
+			`info  hello                                    n=1`,
+		},
+	}
+
+	for name, tc := range tests {
+		var b bytes.Buffer
+
+		opts := append([]Option{WithOutput(&b), WithClock(fixedClock()), WithTimestamp(TimestampNone)}, tc.opts...)
+		l := NewLeveledLogger(opts...)
+
+		caller := callerLine(t)
+		l.Info(tc.keyvals...)
+
+		want := tc.want
+		if name == "caller" {
+			want = strings.Replace(want, "unknown", caller, 1)
+		}
+
+		if got := strings.TrimSpace(b.String()); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
@@ -21,17 +21,59 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/cobaltspeech/log/internal/logmap"
 	"github.com/cobaltspeech/log/pkg/level"
 )
 
 // LeveledLogger implements the Logger interface and uses the go stdlib log
-// package to perform logging.  Each log message has a level prefix followed by
-// JSON representation of the data being logged.
+// package to perform logging.  By default, each log message has a level prefix
+// followed by JSON representation of the data being logged.  The format can be
+// changed by providing an Encoder with WithEncoder.
 type LeveledLogger struct {
-	logger      *log.Logger
-	filterLevel level.Level
+	logger  *log.Logger
+	encoder Encoder
+	keys    KeyNames
+	caller  callerMode
+
+	// stackLevel is the lowest level of the messages with stack traces of up
+	// to stackDepth frames, or level.None if stack traces are disabled.
+	stackLevel level.Level
+	stackDepth int
+
+	// timestamps is whether timestamps are written by the LeveledLogger
+	// rather than by logger.
+	timestamps    bool
+	timePlacement TimestampPlacement
+	timeFormat    string
+	timeLocation  *time.Location
+	clock         func() time.Time
+
+	// stdTimestamps is whether the LeveledLogger writes the timestamp prefix
+	// of log.LstdFlags in place of the logger it created, so that the
+	// messages of an asynchronous logger keep the time they were logged at.
+	stdTimestamps bool
+
+	// output is the Writer given to WithOutput, used to create logger.
+	output io.Writer
+
+	// queue holds the messages of an asynchronous logger, see WithAsync.
+	queue          *asyncQueue
+	queueSize      int
+	queuePolicy    QueuePolicy
+	queueDropLevel level.Level
+
+	// filterLevel holds a level.Level and is only accessed atomically, so that
+	// it may be changed while other goroutines are logging.
+	filterLevel uint32
+
+	// namedLevels holds a map[string]level.Level of the filter levels set for
+	// named loggers.  The map is never modified once stored; namedMu serializes
+	// the replacement of the map.
+	namedLevels atomic.Value
+	namedMu     sync.Mutex
 }
 
 // we define osStdErr so that it can be changed for testing
@@ -39,16 +81,46 @@ var osStderr io.Writer = os.Stderr
 
 // NewLeveledLogger returns a new Leveledlogger that writes Error and Info
 // messages to stderr.  These defaults can be changed by providing Options.
+//
+// Unless an Encoder is provided with WithEncoder, messages are written with a
+// colored ConsoleEncoder if the output is a terminal, and with the JSONEncoder
+// otherwise.
 func NewLeveledLogger(opts ...Option) *LeveledLogger {
 	l := LeveledLogger{}
-	l.filterLevel = level.Default
+	l.filterLevel = uint32(level.Default)
+	l.namedLevels.Store(map[string]level.Level{})
+	l.timeFormat = TimeFormatRFC3339Nano
+	l.timeLocation = time.UTC
+	l.clock = time.Now
+	l.keys = DefaultKeyNames
+	l.queueDropLevel = level.Error
 
 	for _, opt := range opts {
 		opt(&l)
 	}
 
 	if l.logger == nil {
-		l.logger = log.New(osStderr, "", log.LstdFlags)
+		if l.output == nil {
+			l.output = osStderr
+		}
+
+		flags := log.LstdFlags
+		if l.timestamps {
+			flags = 0
+		} else if l.queueSize > 0 {
+			flags = 0
+			l.stdTimestamps = true
+		}
+
+		l.logger = log.New(l.output, "", flags)
+	}
+
+	if l.encoder == nil {
+		l.encoder = defaultEncoder(l.logger.Writer())
+	}
+
+	if l.queueSize > 0 {
+		l.startAsync()
 	}
 
 	return &l
@@ -60,7 +132,8 @@ type Option func(*LeveledLogger)
 // log messages to the given Writer.  Do not combine with WithLogger.
 func WithOutput(w io.Writer) Option {
 	return func(l *LeveledLogger) {
-		l.logger = log.New(w, "", log.LstdFlags)
+		l.logger = nil
+		l.output = w
 	}
 }
 
@@ -69,6 +142,7 @@ func WithOutput(w io.Writer) Option {
 func WithLogger(logger *log.Logger) Option {
 	return func(l *LeveledLogger) {
 		l.logger = logger
+		l.output = nil
 	}
 }
 
@@ -76,7 +150,27 @@ func WithLogger(logger *log.Logger) Option {
 // messages with the specified logging levels.
 func WithFilterLevel(lvl level.Level) Option {
 	return func(l *LeveledLogger) {
-		l.filterLevel = lvl
+		l.filterLevel = uint32(lvl)
+	}
+}
+
+// WithEncoder returns an Option that configures the LeveledLogger to format
+// log messages with the given Encoder.  This disables the selection of an
+// Encoder based on whether the output is a terminal, e.g. providing
+// NewJSONEncoder() always writes JSON and NewConsoleEncoder(true) always
+// writes colored console output.
+func WithEncoder(enc Encoder) Option {
+	return func(l *LeveledLogger) {
+		l.encoder = enc
+	}
+}
+
+// WithNamedFilterLevel configures the new LeveledLogger being created to only
+// log messages of loggers created by Named with the given name, or any of its
+// descendants, with the specified logging levels.  See SetNamedFilterLevel.
+func WithNamedFilterLevel(name string, lvl level.Level) Option {
+	return func(l *LeveledLogger) {
+		l.SetNamedFilterLevel(name, lvl)
 	}
 }
 
@@ -84,51 +178,182 @@ func WithFilterLevel(lvl level.Level) Option {
 // provided level.  An application may want to do this to enable debugging
 // messages in production, without shutting down and reconfiguring the logger.
 //
-// This method is expected to be called rarely, and it does not use mutexes to
-// lock the level change operations.  Applications may observe temporarily
-// indeterminate filtering behavior when this method is called concurrently with
-// other logging methods.
+// The level is stored atomically, so it is safe to call this method
+// concurrently with other logging methods.  Messages logged concurrently with
+// the change are filtered using either the old or the new level.
 func (l *LeveledLogger) SetFilterLevel(lvl level.Level) {
-	l.filterLevel = lvl
+	atomic.StoreUint32(&l.filterLevel, uint32(lvl))
+}
+
+// GetFilterLevel returns the current filter level of the given logger.  It is
+// safe to call concurrently with SetFilterLevel and the logging methods.
+func (l *LeveledLogger) GetFilterLevel() level.Level {
+	return level.Level(atomic.LoadUint32(&l.filterLevel))
+}
+
+// SetNamedFilterLevel changes, at runtime, the level of the messages logged
+// by loggers created by Named with the given name.  The level also applies to
+// the descendants of the named logger, e.g. setting the level of "asr" changes
+// the level of "asr.decoder", unless a level was set for "asr.decoder" itself.
+// Messages of loggers without a level, or any parent with a level, are
+// filtered using the level set by SetFilterLevel.
+//
+// Like SetFilterLevel, it is safe to call this method concurrently with other
+// logging methods.
+func (l *LeveledLogger) SetNamedFilterLevel(name string, lvl level.Level) {
+	l.updateNamedLevels(func(m map[string]level.Level) {
+		m[name] = lvl
+	})
+}
+
+// ResetNamedFilterLevel removes the level set by SetNamedFilterLevel for the
+// given name, so that its messages are filtered using the level of its parent
+// again.
+func (l *LeveledLogger) ResetNamedFilterLevel(name string) {
+	l.updateNamedLevels(func(m map[string]level.Level) {
+		delete(m, name)
+	})
+}
+
+// GetNamedFilterLevel returns the level used to filter the messages of loggers
+// created by Named with the given name.
+func (l *LeveledLogger) GetNamedFilterLevel(name string) level.Level {
+	levels := l.namedLevels.Load().(map[string]level.Level)
+
+	for ok := true; ok; name, ok = parentName(name) {
+		if lvl, found := levels[name]; found {
+			return lvl
+		}
+	}
+
+	return l.GetFilterLevel()
+}
+
+// updateNamedLevels replaces the map of named levels with an updated copy.
+func (l *LeveledLogger) updateNamedLevels(update func(map[string]level.Level)) {
+	l.namedMu.Lock()
+	defer l.namedMu.Unlock()
+
+	old := l.namedLevels.Load().(map[string]level.Level)
+
+	m := make(map[string]level.Level, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+
+	update(m)
+	l.namedLevels.Store(m)
+}
+
+// enabled reports whether a message with the given level and keyvals passes
+// the filter levels of the logger.
+func (l *LeveledLogger) enabled(lvl level.Level, keyvals []interface{}) bool {
+	if len(l.namedLevels.Load().(map[string]level.Level)) == 0 {
+		return l.GetFilterLevel()&lvl > 0
+	}
+
+	name, ok := nameFromKeyvals(keyvals)
+	if !ok {
+		return l.GetFilterLevel()&lvl > 0
+	}
+
+	return l.GetNamedFilterLevel(name)&lvl > 0
 }
 
 // Error sends the given key value pairs to the error logger.
 func (l *LeveledLogger) Error(keyvals ...interface{}) {
-	if l.filterLevel&level.Error > 0 {
+	if l.enabled(level.Error, keyvals) {
 		l.log(level.Error, keyvals...)
 	}
 }
 
 // Info sends the given key value pairs to the info logger.
 func (l *LeveledLogger) Info(keyvals ...interface{}) {
-	if l.filterLevel&level.Info > 0 {
+	if l.enabled(level.Info, keyvals) {
 		l.log(level.Info, keyvals...)
 	}
 }
 
 // Debug sends the given key value pairs to the debug logger.
 func (l *LeveledLogger) Debug(keyvals ...interface{}) {
-	if l.filterLevel&level.Debug > 0 {
+	if l.enabled(level.Debug, keyvals) {
 		l.log(level.Debug, keyvals...)
 	}
 }
 
 // Trace sends the given key value pairs to the trace logger.
 func (l *LeveledLogger) Trace(keyvals ...interface{}) {
-	if l.filterLevel&level.Trace > 0 {
+	if l.enabled(level.Trace, keyvals) {
 		l.log(level.Trace, keyvals...)
 	}
 }
 
 func (l *LeveledLogger) log(lvl level.Level, keyvals ...interface{}) {
-	ms := logmap.FromKeyvals(keyvals...)
+	l.write(lvl, l.line(lvl, keyvals))
+}
+
+// line returns the encoded message, including the reserved fields and the
+// timestamp prefix.
+func (l *LeveledLogger) line(lvl level.Level, keyvals []interface{}) []byte {
+	now := l.clock()
+
+	var fields []interface{}
+
+	if l.timestamps && l.timePlacement == TimestampField {
+		fields = append(fields, l.keys.Time, l.timestamp(now))
+	}
 
-	line, err := ms.JSONString()
+	caller, kvs, ok := takeCallerOverride(keyvals)
+
+	switch {
+	case ok && caller == "":
+		keyvals = kvs
+	case l.caller == callerNone:
+	case ok:
+		keyvals = kvs
+		fields = append(fields, l.keys.Caller, caller)
+	default:
+		fields = append(fields, l.keys.Caller, callerString(l.caller))
+	}
+
+	if l.stackLevel != level.None && lvl >= l.stackLevel {
+		fields = append(fields, l.keys.Stacktrace, stacktrace(l.stackDepth))
+	}
+
+	keyvals = l.withFields(fields, keyvals)
+
+	line, err := l.encoder.Encode(&Entry{Time: now, Level: lvl, Keyvals: keyvals, Keys: l.keys})
 	if err != nil {
-		l.logger.Printf(`%-5s {"msg":"logging failure","error":%q}`, level.Error, err)
+		line = l.encodeFailure(now, err)
+	}
+
+	if l.timestamps && l.timePlacement == TimestampPrefix {
+		line = append([]byte(fmt.Sprint(l.timestamp(now))+" "), line...)
+	}
+
+	if l.stdTimestamps {
+		line = append([]byte(now.Format(stdTimeFormat)), line...)
+	}
+
+	return line
+}
+
+// encodeFailure returns the line reporting that an entry could not be encoded.
+// The configured encoder is given a chance to format the report, so that the
+// output stays parseable, and a JSON line is used if that fails as well.
+func (l *LeveledLogger) encodeFailure(now time.Time, err error) []byte {
+	var fields []interface{}
+
+	if l.timestamps && l.timePlacement == TimestampField {
+		fields = append(fields, l.keys.Time, l.timestamp(now))
+	}
+
+	keyvals := l.withFields(fields, []interface{}{MessageKey, "logging failure", "error", err.Error()})
 
-		return
+	line, encErr := l.encoder.Encode(&Entry{Time: now, Level: level.Error, Keyvals: keyvals, Keys: l.keys})
+	if encErr != nil {
+		return []byte(fmt.Sprintf(`%-5s {%q:"logging failure","error":%q}`, level.Error, l.keys.Message, err))
 	}
 
-	l.logger.Print(fmt.Sprintf("%-5s %s", lvl, line))
+	return line
 }
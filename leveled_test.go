@@ -185,6 +185,46 @@ func TestLeveledLogger_Concurrent(t *testing.T) {
 	}
 }
 
+func TestLeveledLogger_SetFilterLevel_Concurrent(t *testing.T) {
+	// this test verifies that the filter level can be changed while other
+	// goroutines are logging.  It is meant to be run with the race detector.
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)))
+
+	var wg sync.WaitGroup
+
+	N := 100
+	wg.Add(2 * N)
+
+	for i := 0; i < N; i++ {
+		go func(i int) {
+			defer wg.Done()
+
+			l.Error("msg", "concurrent_level_test", "i", i)
+			l.Info("msg", "concurrent_level_test", "i", i)
+			l.Debug("msg", "concurrent_level_test", "i", i)
+			l.Trace("msg", "concurrent_level_test", "i", i)
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if l.GetFilterLevel() == level.All {
+				l.SetFilterLevel(level.Default)
+			} else {
+				l.SetFilterLevel(level.All)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	l.SetFilterLevel(level.Debug | level.Error)
+
+	if got, want := l.GetFilterLevel(), level.Debug|level.Error; got != want {
+		t.Errorf("GetFilterLevel: got %v, want %v", got, want)
+	}
+}
+
 // failingTextMarshaler implements encoding.TextMarshaler that fails
 type failingTextMarshaler struct{}
 
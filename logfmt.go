@@ -0,0 +1,206 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// LogfmtEncoder is an Encoder that writes log messages in the logfmt format,
+// with the level as the first field:
+//
+//	level=info msg="server started" port=8080
+//
+// Values are formatted following the same priority rules as the JSONEncoder:
+// values implementing json.Marshaler are written as compact JSON, values
+// implementing encoding.TextMarshaler are written with MarshalText, and all
+// other values with fmt.Sprint.  Values that are empty or contain spaces,
+// quotes, equal signs or control characters are quoted and escaped.  Invalid
+// characters in keys are replaced by underscores.  The level is keyed by the
+// Level of the Entry's key names, and keyvals with the same key are prefixed
+// with ReservedKeyPrefix.
+type LogfmtEncoder struct{}
+
+// NewLogfmtEncoder returns a new LogfmtEncoder.
+func NewLogfmtEncoder() *LogfmtEncoder {
+	return &LogfmtEncoder{}
+}
+
+// Encode implements the Encoder interface.
+func (enc *LogfmtEncoder) Encode(e *Entry) ([]byte, error) {
+	var buf bytes.Buffer
+
+	levelKey := e.Keys.withDefaults().Level
+
+	buf.WriteString(logfmtKey(levelKey))
+	buf.WriteByte('=')
+	buf.WriteString(e.Level.String())
+
+	ms := logmap.FromKeyvals(e.Keyvals...)
+	renameReservedItem(ms, levelKey)
+
+	for _, item := range ms {
+		val, err := logmap.TextFromValue(item.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteByte(' ')
+		buf.WriteString(logfmtKey(item.Key))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(val))
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// logfmtKey replaces the characters that may not appear in a logfmt key.
+func logfmtKey(k string) string {
+	if k == "" {
+		return "_"
+	}
+
+	return strings.Map(func(r rune) rune {
+		if logfmtNeedsQuote(r) {
+			return '_'
+		}
+
+		return r
+	}, k)
+}
+
+// logfmtValue quotes the value if it cannot be written as a bare logfmt value.
+func logfmtValue(v string) string {
+	if v == "" || strings.IndexFunc(v, logfmtNeedsQuote) >= 0 {
+		return strconv.Quote(v)
+	}
+
+	return v
+}
+
+func logfmtNeedsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f || r == utf8.RuneError
+}
+
+// ErrLogfmtSyntax is returned by DecodeLogfmt when the line is not valid logfmt.
+var ErrLogfmtSyntax = errors.New("logfmt syntax error")
+
+// DecodeLogfmt parses a line written by the LogfmtEncoder.  The "level" field
+// is removed from the keyvals and used as the Level of the returned Entry; all
+// other keys and values are returned as strings in the order they appear.
+// Keys without a value (e.g. "key" rather than "key=value") are given an
+// empty value.
+func DecodeLogfmt(line []byte) (*Entry, error) {
+	e := &Entry{}
+	s := strings.TrimRight(string(line), "\r\n")
+	levelSeen := false
+
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+
+			continue
+		}
+
+		key, n, err := decodeLogfmtKey(s[i:])
+		if err != nil {
+			return nil, fmt.Errorf("%w at offset %d: %v", ErrLogfmtSyntax, i, err)
+		}
+
+		i += n
+
+		var val string
+
+		if i < len(s) && s[i] == '=' {
+			i++
+
+			val, n, err = decodeLogfmtValue(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at offset %d: %v", ErrLogfmtSyntax, i, err)
+			}
+
+			i += n
+		}
+
+		if key == "level" && !levelSeen {
+			e.Level = level.FromString(val)
+			levelSeen = true
+
+			continue
+		}
+
+		e.Keyvals = append(e.Keyvals, key, val)
+	}
+
+	return e, nil
+}
+
+var (
+	errLogfmtEmptyKey     = errors.New("empty key")
+	errLogfmtUnterminated = errors.New("unterminated quoted value")
+)
+
+// decodeLogfmtKey returns the key at the start of s and the number of bytes it
+// occupies.
+func decodeLogfmtKey(s string) (string, int, error) {
+	n := strings.IndexAny(s, "= \t")
+	if n < 0 {
+		n = len(s)
+	}
+
+	if n == 0 {
+		return "", 0, errLogfmtEmptyKey
+	}
+
+	return s[:n], n, nil
+}
+
+// decodeLogfmtValue returns the, possibly quoted, value at the start of s and
+// the number of bytes it occupies.
+func decodeLogfmtValue(s string) (string, int, error) {
+	if s == "" || s[0] != '"' {
+		n := strings.IndexAny(s, " \t")
+		if n < 0 {
+			n = len(s)
+		}
+
+		return s[:n], n, nil
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			val, err := strconv.Unquote(s[:i+1])
+
+			return val, i + 1, err
+		}
+	}
+
+	return "", 0, errLogfmtUnterminated
+}
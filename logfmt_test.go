@@ -0,0 +1,157 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	enc := NewLogfmtEncoder()
+
+	tests := map[string]struct {
+		entry Entry
+		want  string
+	}{
+		"empty": {
+			Entry{Level: level.Info},
+			"level=info\n",
+		},
+		"simple": {
+			Entry{Level: level.Error, Keyvals: []interface{}{"msg", "hello", "port", 8080}},
+			"level=error msg=hello port=8080\n",
+		},
+		"quoting": {
+			Entry{Level: level.Debug, Keyvals: []interface{}{
+				"msg", "hello world", "quote", `say "hi"`, "eq", "a=b", "nl", "one\ntwo", "empty", "",
+			}},
+			`level=debug msg="hello world" quote="say \"hi\"" eq="a=b" nl="one\ntwo" empty=""` + "\n",
+		},
+		"keys": {
+			Entry{Level: level.Trace, Keyvals: []interface{}{"my key", 1, "", 2, "a=b", 3}},
+			"level=trace my_key=1 _=2 a_b=3\n",
+		},
+		"missing": {
+			Entry{Level: level.Info, Keyvals: []interface{}{"msg"}},
+			"level=info msg=missing\n",
+		},
+		"marshalers": {
+			Entry{Level: level.Info, Keyvals: []interface{}{"ip", net.ParseIP("10.0.0.1"), "json", jsonValue{}}},
+			`level=info ip=10.0.0.1 json="{\"a\":1}"` + "\n",
+		},
+		"time": {
+			Entry{Level: level.Info, Keyvals: []interface{}{"t", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)}},
+			"level=info t=2021-03-04T00:00:00Z\n",
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := enc.Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+
+	if _, err := enc.Encode(&Entry{Level: level.Info, Keyvals: []interface{}{"x", &failingTextMarshaler{}}}); err == nil {
+		t.Error("expected an error from a failing encoding.TextMarshaler")
+	}
+}
+
+// jsonValue implements json.Marshaler as well as encoding.TextMarshaler.
+type jsonValue struct{}
+
+func (jsonValue) MarshalJSON() ([]byte, error) { return []byte(`{"a": 1}`), nil }
+func (jsonValue) MarshalText() ([]byte, error) { return []byte("text"), nil }
+
+func TestDecodeLogfmt(t *testing.T) {
+	tests := map[string]struct {
+		line string
+		want *Entry
+	}{
+		"empty": {
+			"",
+			&Entry{},
+		},
+		"simple": {
+			"level=error msg=hello port=8080\n",
+			&Entry{Level: level.Error, Keyvals: []interface{}{"msg", "hello", "port", "8080"}},
+		},
+		"quoted": {
+			`level=debug msg="hello world" quote="say \"hi\"" nl="one\ntwo" empty=""`,
+			&Entry{Level: level.Debug, Keyvals: []interface{}{
+				"msg", "hello world", "quote", `say "hi"`, "nl", "one\ntwo", "empty", "",
+			}},
+		},
+		"bare_key": {
+			"level=info flag  other=1",
+			&Entry{Level: level.Info, Keyvals: []interface{}{"flag", "", "other", "1"}},
+		},
+		"second_level": {
+			"level=info level=custom",
+			&Entry{Level: level.Info, Keyvals: []interface{}{"level", "custom"}},
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := DecodeLogfmt([]byte(tc.line))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: unexpected entry (-want +got):\n%s", name, diff)
+		}
+	}
+
+	for _, line := range []string{`msg="unterminated`, "=value", `msg="\q"`} {
+		if _, err := DecodeLogfmt([]byte(line)); !errors.Is(err, ErrLogfmtSyntax) {
+			t.Errorf("DecodeLogfmt(%q): got error %v, want ErrLogfmtSyntax", line, err)
+		}
+	}
+}
+
+func TestLogfmt_roundTrip(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithEncoder(NewLogfmtEncoder()))
+
+	keyvals := []interface{}{"msg", "a \"tricky\"\tvalue\\ with\nnewlines", "key", "=", "unicode", "héllo wörld"}
+	l.Info(append(keyvals, "t", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC))...)
+
+	got, err := DecodeLogfmt(b.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &Entry{Level: level.Info, Keyvals: append(keyvals, "t", "2021-03-04T00:00:00Z")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected round trip (-want +got):\n%s", diff)
+	}
+}
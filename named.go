@@ -0,0 +1,113 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import "strings"
+
+// NameKey is the key of the field holding the name of loggers created by
+// Named.  A LeveledLogger uses the value of this field to select the filter
+// level of the message, so it should not be used for other purposes.
+const NameKey = "logger"
+
+// Named returns a new Logger that adds a NameKey field with the given name to
+// all messages.  If l was itself created by Named, possibly wrapped by With,
+// the new name is appended to the name of l with a dot, so that
+//
+//	Named(Named(l, "asr"), "decoder")
+//
+// logs messages with the name "asr.decoder".
+//
+// When the messages reach a LeveledLogger, they are filtered using the level
+// set for their name with WithNamedFilterLevel or SetNamedFilterLevel.  If none
+// was set, the level of the closest parent name is used, and the filter level
+// of the LeveledLogger if no parent has a level either.
+func Named(l Logger, name string) Logger {
+	if name == "" {
+		return l
+	}
+
+	if n, ok := l.(namer); ok && n.loggerName() != "" {
+		name = n.loggerName() + "." + name
+	}
+
+	return &namedLogger{l, name}
+}
+
+// namer is implemented by the Loggers of this package that know the name of
+// the messages they log.
+type namer interface {
+	loggerName() string
+}
+
+type namedLogger struct {
+	log  Logger
+	name string
+}
+
+func (n *namedLogger) loggerName() string {
+	return n.name
+}
+
+// keyvals prepends the name to keyvals, unless it was already added by a
+// descendant of n, which has a longer name.
+func (n *namedLogger) keyvals(keyvals []interface{}) []interface{} {
+	if _, ok := nameFromKeyvals(keyvals); ok {
+		return keyvals
+	}
+
+	return append([]interface{}{NameKey, n.name}, keyvals...)
+}
+
+func (n *namedLogger) Error(keyvals ...interface{}) {
+	n.log.Error(n.keyvals(keyvals)...)
+}
+
+func (n *namedLogger) Info(keyvals ...interface{}) {
+	n.log.Info(n.keyvals(keyvals)...)
+}
+
+func (n *namedLogger) Debug(keyvals ...interface{}) {
+	n.log.Debug(n.keyvals(keyvals)...)
+}
+
+func (n *namedLogger) Trace(keyvals ...interface{}) {
+	n.log.Trace(n.keyvals(keyvals)...)
+}
+
+// nameFromKeyvals returns the value of the first NameKey field of keyvals.
+func nameFromKeyvals(keyvals []interface{}) (string, bool) {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if k, ok := keyvals[i].(string); ok && k == NameKey {
+			name, ok := keyvals[i+1].(string)
+
+			return name, ok
+		}
+	}
+
+	return "", false
+}
+
+// parentName returns the name of the parent of the named logger, e.g. "asr"
+// for "asr.decoder", and false if name has no parent.
+func parentName(name string) (string, bool) {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return "", false
+	}
+
+	return name[:i], true
+}
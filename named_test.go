@@ -0,0 +1,128 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestNamed(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithFilterLevel(level.All))
+
+	asr := Named(l, "asr")
+	asr.Info("msg", "asr")
+
+	decoder := Named(asr, "decoder")
+	decoder.Info("msg", "decoder")
+
+	// With does not hide the name of the parent logger.
+	ctx := Named(With(asr, "key", "value"), "lm")
+	ctx.Info("msg", "lm")
+
+	if Named(l, "") != l {
+		t.Error("Named with an empty name should return the logger")
+	}
+
+	want := `info  {"logger":"asr","msg":"asr"}
+info  {"logger":"asr.decoder","msg":"decoder"}
+info  {"key":"value","logger":"asr.lm","msg":"lm"}
+`
+
+	if got := b.String(); got != want {
+		t.Log(got)
+		t.Log(want)
+		t.Errorf("Named: got %q, want %q", got, want)
+	}
+}
+
+func TestLeveledLogger_SetNamedFilterLevel(t *testing.T) {
+	writelogs := func(l Logger) {
+		l.Debug("msg", "debug_message")
+		l.Info("msg", "info_message")
+	}
+
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithNamedFilterLevel("asr", level.All))
+
+	root := Named(l, "root")
+	asr := Named(l, "asr")
+	decoder := Named(asr, "decoder")
+	asrx := Named(l, "asrx")
+
+	loggers := []Logger{l, root, asr, decoder, asrx}
+	for _, lg := range loggers {
+		writelogs(lg)
+	}
+
+	l.SetNamedFilterLevel("asr.decoder", level.Error)
+	l.SetNamedFilterLevel("root", level.Debug)
+
+	for _, lg := range loggers {
+		writelogs(lg)
+	}
+
+	l.ResetNamedFilterLevel("asr")
+	l.ResetNamedFilterLevel("root")
+
+	for _, lg := range loggers {
+		writelogs(lg)
+	}
+
+	want := `info  {"msg":"info_message"}
+info  {"logger":"root","msg":"info_message"}
+debug {"logger":"asr","msg":"debug_message"}
+info  {"logger":"asr","msg":"info_message"}
+debug {"logger":"asr.decoder","msg":"debug_message"}
+info  {"logger":"asr.decoder","msg":"info_message"}
+info  {"logger":"asrx","msg":"info_message"}
+info  {"msg":"info_message"}
+debug {"logger":"root","msg":"debug_message"}
+debug {"logger":"asr","msg":"debug_message"}
+info  {"logger":"asr","msg":"info_message"}
+info  {"logger":"asrx","msg":"info_message"}
+info  {"msg":"info_message"}
+info  {"logger":"root","msg":"info_message"}
+info  {"logger":"asr","msg":"info_message"}
+info  {"logger":"asrx","msg":"info_message"}
+`
+
+	if got := b.String(); strings.TrimSpace(got) != strings.TrimSpace(want) {
+		t.Log(got)
+		t.Log(want)
+		t.Errorf("SetNamedFilterLevel: got %q, want %q", got, want)
+	}
+
+	tests := map[string]level.Level{
+		"":                level.Default,
+		"asr":             level.Default,
+		"asr.decoder":     level.Error,
+		"asr.decoder.sub": level.Error,
+		"asrx":            level.Default,
+	}
+
+	for name, want := range tests {
+		if got := l.GetNamedFilterLevel(name); got != want {
+			t.Errorf("GetNamedFilterLevel(%q): got %v, want %v", name, got, want)
+		}
+	}
+}
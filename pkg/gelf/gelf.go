@@ -0,0 +1,225 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package gelf sends log messages to Graylog, formatted according to version
+// 1.1 of the Graylog Extended Log Format.  Use NewLogger to create a
+// LeveledLogger sending messages to a Writer returned by Dial:
+//
+//	w, err := gelf.Dial("udp", "graylog.example.com:12201", gelf.WithCompression(gelf.CompressGzip))
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	logger := gelf.NewLogger(w, log.WithFilterLevel(level.All))
+package gelf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// Version is the version of GELF written by the Encoder.
+const Version = "1.1"
+
+// Syslog severities used for the levels of the messages.  Trace messages have
+// the debug severity, like Debug messages.
+const (
+	severityError = 3
+	severityInfo  = 6
+	severityDebug = 7
+)
+
+// emptyMessage is the short message of the messages without message field, as
+// GELF requires a non-empty short message.
+const emptyMessage = "-"
+
+// reservedID is the name of the additional field that GELF reserves.  Fields
+// with this name are renamed with the log.ReservedKeyPrefix.
+const reservedID = "_id"
+
+// config holds the settings of the Encoder and Writer.
+type config struct {
+	host        string
+	messageKey  string
+	compression Compression
+	chunkSize   int
+}
+
+// Option configures an Encoder, or the Encoder and Writer returned by Dial.
+type Option func(*config)
+
+// WithHost returns an Option that sets the host field of the messages, the name
+// returned by os.Hostname by default.
+func WithHost(host string) Option {
+	return func(c *config) {
+		c.host = host
+	}
+}
+
+// WithMessageKey returns an Option that sets the key of the field used as the
+// short message of the messages, log.MessageKey by default.  It must match the
+// name given to log.WithKeyNames, if any.
+func WithMessageKey(key string) Option {
+	return func(c *config) {
+		c.messageKey = key
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		messageKey: log.MessageKey,
+		chunkSize:  DefaultChunkSize,
+	}
+
+	c.host, _ = os.Hostname()
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// Encoder is a log.Encoder formatting messages according to GELF 1.1:
+//
+//	{"version":"1.1","host":"host","short_message":"server started","timestamp":1614852367.890123,"level":6,"_port":8080}
+//
+// The field with the log.MessageKey key is the short message, and the level is
+// written as a syslog severity.  The other fields are written as additional
+// fields, whose names are prefixed with an underscore and restricted to the
+// characters allowed by GELF.  Integers and floating point numbers keep their
+// native type, and other values are written as strings.
+type Encoder struct {
+	config
+}
+
+// NewEncoder returns a new Encoder.  Its defaults can be changed by providing
+// Options.
+func NewEncoder(opts ...Option) *Encoder {
+	return &Encoder{newConfig(opts)}
+}
+
+// Encode implements the log.Encoder interface.
+func (enc *Encoder) Encode(e *log.Entry) ([]byte, error) {
+	fields := logmap.FromKeyvalsTyped(e.Keyvals...)
+
+	ms := make(logmap.MapSlice, 0, len(fields)+5) //nolint:gomnd // number of GELF fields
+	ms = append(ms,
+		logmap.MapItem{Key: "version", Value: Version},
+		logmap.MapItem{Key: "host", Value: enc.host},
+		logmap.MapItem{Key: "short_message", Value: emptyMessage},
+		logmap.MapItem{Key: "timestamp", Value: timestamp(e)},
+		logmap.MapItem{Key: "level", Value: severity(e.Level)},
+	)
+
+	const msgIndex = 2
+
+	hasMsg := false
+
+	for _, item := range fields {
+		value, err := fieldValue(item.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Key == enc.messageKey && !hasMsg {
+			hasMsg = true
+
+			if s, ok := value.(string); ok && s != "" {
+				ms[msgIndex].Value = s
+			}
+
+			continue
+		}
+
+		ms = append(ms, logmap.MapItem{Key: fieldName(item.Key), Value: value})
+	}
+
+	line, err := ms.JSONString()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(line), nil
+}
+
+// timestamp returns the time of the entry as the number of seconds since the
+// Unix epoch, with microsecond precision.
+func timestamp(e *log.Entry) json.Number {
+	us := e.Time.UnixNano() / 1000 //nolint:gomnd // microseconds
+
+	sec, frac := us/1e6, us%1e6
+	if frac < 0 {
+		sec, frac = sec-1, frac+1e6
+	}
+
+	return json.Number(fmt.Sprintf("%d.%06d", sec, frac))
+}
+
+// severity returns the severity of the level.
+func severity(lvl level.Level) int {
+	switch lvl {
+	case level.Error:
+		return severityError
+	case level.Info:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// fieldValue returns the value of an additional field, which GELF restricts to
+// strings and numbers.
+func fieldValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, string:
+		return v, nil
+	case json.RawMessage:
+		return string(val), nil
+	default:
+		return logmap.TextFromValue(v)
+	}
+}
+
+// fieldName returns the name of an additional field, prefixed with an
+// underscore and with the characters not allowed by GELF replaced with
+// underscores.
+func fieldName(key string) string {
+	name := "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '_', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	if name == reservedID {
+		name = "_" + log.ReservedKeyPrefix + name[1:]
+	}
+
+	return name
+}
@@ -0,0 +1,79 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gelf
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+type failingTextMarshaler struct{}
+
+func (failingTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("marshal failure")
+}
+
+func TestEncoder(t *testing.T) {
+	tm := time.Date(2021, 3, 4, 10, 6, 7, 890123456, time.UTC)
+
+	tests := map[string]struct {
+		opts  []Option
+		entry log.Entry
+		want  string
+	}{
+		"fields": {
+			[]Option{WithHost("host")},
+			log.Entry{Time: tm, Level: level.Info, Keyvals: []interface{}{
+				"port", 8080, "msg", "server started", "load", 0.5, "tls", true, "tags", []string{"a"}, "a key", "x", "id", 7,
+			}},
+			`{"version":"1.1","host":"host","short_message":"server started","timestamp":1614852367.890123,"level":6,` +
+				`"_port":8080,"_load":0.5,"_tls":"true","_tags":"[\"a\"]","_a_key":"x","_fields.id":7}`,
+		},
+		"no_message": {
+			[]Option{WithHost("host"), WithMessageKey("message")},
+			log.Entry{Time: tm, Level: level.Error, Keyvals: []interface{}{"msg", "not the message"}},
+			`{"version":"1.1","host":"host","short_message":"-","timestamp":1614852367.890123,"level":3,` +
+				`"_msg":"not the message"}`,
+		},
+		"debug": {
+			[]Option{WithHost("host")},
+			log.Entry{Time: tm.Add(-890123456), Level: level.Trace, Keyvals: []interface{}{"msg", ""}},
+			`{"version":"1.1","host":"host","short_message":"-","timestamp":1614852367.000000,"level":7}`,
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := NewEncoder(tc.opts...).Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if strings.TrimSuffix(string(got), "\n") != tc.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", name, got, tc.want)
+		}
+	}
+
+	_, err := NewEncoder().Encode(&log.Entry{Keyvals: []interface{}{"key", failingTextMarshaler{}}})
+	if err == nil {
+		t.Error("expected an error for a failing TextMarshaler")
+	}
+}
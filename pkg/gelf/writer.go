@@ -0,0 +1,267 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/netconn"
+)
+
+// Compression enumerates the compression algorithms of the messages sent over
+// UDP.
+type Compression byte
+
+const (
+	// CompressNone sends uncompressed messages.
+	CompressNone Compression = iota
+
+	// CompressGzip compresses messages with gzip.
+	CompressGzip
+
+	// CompressZlib compresses messages with zlib.
+	CompressZlib
+)
+
+// DefaultChunkSize is the default maximum size of the UDP datagrams, including
+// the chunk header, suitable for networks with a standard MTU.  See
+// WithChunkSize.
+const DefaultChunkSize = 1420
+
+// MaxChunks is the maximum number of chunks of a message sent over UDP.
+const MaxChunks = 128
+
+// chunkHeaderSize is the size of the header of UDP chunks: two magic bytes,
+// an eight byte message ID, the sequence number and the sequence count.
+const chunkHeaderSize = 12
+
+// chunkMagic are the magic bytes starting the header of UDP chunks.
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// ErrMessageTooLarge is returned by Write when a message would need more than
+// MaxChunks chunks to be sent over UDP.
+var ErrMessageTooLarge = errors.New("gelf: message too large")
+
+// ErrClosed is returned by Write and Close after Close.
+var ErrClosed = errors.New("gelf: writer closed")
+
+// WithCompression returns an Option that sets the compression of the messages
+// sent over UDP, CompressNone by default.  Messages sent over TCP are never
+// compressed, as Graylog does not support it.
+func WithCompression(c Compression) Option {
+	return func(cfg *config) {
+		cfg.compression = c
+	}
+}
+
+// WithChunkSize returns an Option that sets the maximum size of the datagrams
+// sent over UDP, DefaultChunkSize by default.  Larger messages are split into
+// up to MaxChunks chunks of that size.  Networks with a large MTU may use up to
+// 8192 bytes.
+func WithChunkSize(size int) Option {
+	return func(c *config) {
+		c.chunkSize = size
+	}
+}
+
+// Writer is an io.Writer sending each write to a Graylog server as a GELF
+// message.  It is meant to be used by a LeveledLogger created by NewLogger,
+// which writes the messages formatted by the Encoder of the Writer.
+//
+// Over UDP, messages are compressed as configured by WithCompression and split
+// into chunks when larger than the chunk size.  Over TCP, messages are
+// delimited by null bytes.  If sending a message fails, for instance because
+// the server restarted, the Writer reconnects and sends the message again
+// once.  A Writer is safe for concurrent use.
+type Writer struct {
+	network string
+	raddr   string
+	encoder *Encoder
+	stream  bool
+	conn    *netconn.Conn
+}
+
+// Dial returns a new Writer connected to the Graylog GELF input at the given
+// address.  The network may be "udp" or "tcp", or their variants accepted by
+// net.Dial.
+//
+// The Options configure the format of the messages, see NewEncoder, and their
+// compression and chunking over UDP.
+func Dial(network, raddr string, opts ...Option) (*Writer, error) {
+	w := &Writer{network: network, raddr: raddr, encoder: NewEncoder(opts...)}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		w.stream = true
+	case "udp", "udp4", "udp6":
+		if w.encoder.chunkSize <= chunkHeaderSize {
+			return nil, fmt.Errorf("gelf: chunk size %d is too small", w.encoder.chunkSize)
+		}
+	default:
+		return nil, fmt.Errorf("gelf: unsupported network %q", network)
+	}
+
+	conn, err := netconn.Dial(w.dial, ErrClosed)
+	if err != nil {
+		return nil, err
+	}
+
+	w.conn = conn
+
+	return w, nil
+}
+
+// NewLogger returns a new LeveledLogger sending its messages to w, formatted
+// by the Encoder of w.  The Options are applied before those setting the
+// output and the Encoder.
+func NewLogger(w *Writer, opts ...log.Option) *log.LeveledLogger {
+	opts = append(opts, log.WithOutput(w), log.WithEncoder(w.Encoder()), log.WithTimestamp(log.TimestampNone))
+
+	return log.NewLeveledLogger(opts...)
+}
+
+// Encoder returns the Encoder formatting the messages sent by w.
+func (w *Writer) Encoder() *Encoder {
+	return w.encoder
+}
+
+// Write sends p as a message, without its trailing newline.
+func (w *Writer) Write(p []byte) (int, error) {
+	msg := bytes.TrimSuffix(p, []byte{'\n'})
+
+	var (
+		packets [][]byte
+		err     error
+	)
+
+	if w.stream {
+		packets = [][]byte{append(msg[:len(msg):len(msg)], 0)}
+	} else if packets, err = w.datagrams(msg); err != nil {
+		return 0, err
+	}
+
+	err = w.conn.Send(func(conn net.Conn) error {
+		return send(conn, packets)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the server.  The Writer cannot be used
+// afterwards.
+func (w *Writer) Close() error {
+	return w.conn.Close()
+}
+
+// dial connects to the server.
+func (w *Writer) dial() (net.Conn, error) {
+	return net.DialTimeout(w.network, w.raddr, netconn.DialTimeout)
+}
+
+// datagrams returns the UDP datagrams sending the message, compressed and
+// split into chunks as configured.
+func (w *Writer) datagrams(msg []byte) ([][]byte, error) {
+	msg, err := compress(msg, w.encoder.compression)
+	if err != nil {
+		return nil, err
+	}
+
+	size := w.encoder.chunkSize
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+
+	size -= chunkHeaderSize
+
+	count := (len(msg) + size - 1) / size
+	if count > MaxChunks {
+		return nil, ErrMessageTooLarge
+	}
+
+	id := make([]byte, 8) //nolint:gomnd // size of message IDs
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	chunks := make([][]byte, 0, count)
+
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		chunk := make([]byte, 0, chunkHeaderSize+end-i*size)
+		chunk = append(chunk, chunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, msg[i*size:end]...)
+
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+// compress compresses the message with the given algorithm.
+func compress(msg []byte, c Compression) ([]byte, error) {
+	var (
+		b  bytes.Buffer
+		zw io.WriteCloser
+	)
+
+	switch c {
+	case CompressGzip:
+		zw = gzip.NewWriter(&b)
+	case CompressZlib:
+		zw = zlib.NewWriter(&b)
+	default:
+		return msg, nil
+	}
+
+	if _, err := zw.Write(msg); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// send sends the packets over the connection.
+func send(conn net.Conn, packets [][]byte) error {
+	for _, p := range packets {
+		if _, err := conn.Write(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// listenUDP returns a UDP connection listening on a random local port.
+func listenUDP(t *testing.T) net.PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// readDatagram reads a datagram from the connection.
+func readDatagram(t *testing.T, conn net.PacketConn) []byte {
+	t.Helper()
+
+	buf := make([]byte, 65536)
+
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf[:n]
+}
+
+func TestWriter_udp(t *testing.T) {
+	conn := listenUDP(t)
+
+	w, err := Dial("udp", conn.LocalAddr().String(), WithHost("host"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	NewLogger(w).Info("msg", "hello", "n", 1)
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(readDatagram(t, conn), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	if m["short_message"] != "hello" || m["host"] != "host" || m["level"] != 6.0 || m["_n"] != 1.0 {
+		t.Errorf("unexpected message %v", m)
+	}
+}
+
+func TestWriter_udpChunks(t *testing.T) {
+	tests := map[string]struct {
+		compression Compression
+		decompress  func(io.Reader) (io.Reader, error)
+	}{
+		"none": {CompressNone, func(r io.Reader) (io.Reader, error) { return r, nil }},
+		"gzip": {CompressGzip, func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		"zlib": {CompressZlib, func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+	}
+
+	for name, tc := range tests {
+		conn := listenUDP(t)
+
+		w, err := Dial("udp", conn.LocalAddr().String(), WithCompression(tc.compression), WithChunkSize(100))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Random hexadecimal digits do not compress below a few chunks.
+		rnd := rand.New(rand.NewSource(1))
+
+		msg := make([]byte, 500)
+		for i := range msg {
+			msg[i] = "0123456789abcdef"[rnd.Intn(16)]
+		}
+
+		if _, err := w.Write(append(msg, '\n')); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		w.Close()
+
+		var (
+			payload bytes.Buffer
+			id      []byte
+			count   int
+		)
+
+		for seq := 0; seq == 0 || seq < count; seq++ {
+			chunk := readDatagram(t, conn)
+
+			if len(chunk) > 100 || !bytes.Equal(chunk[:2], chunkMagic) || int(chunk[10]) != seq {
+				t.Fatalf("%s: unexpected chunk %d: %q", name, seq, chunk)
+			}
+
+			if seq == 0 {
+				id, count = chunk[2:10], int(chunk[11])
+			} else if !bytes.Equal(chunk[2:10], id) || int(chunk[11]) != count {
+				t.Fatalf("%s: unexpected header of chunk %d: %q", name, seq, chunk[:12])
+			}
+
+			payload.Write(chunk[12:])
+		}
+
+		r, err := tc.decompress(&payload)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if !bytes.Equal(got, msg) {
+			t.Errorf("%s: got %q, want %q", name, got, msg)
+		}
+	}
+}
+
+func TestWriter_udpTooLarge(t *testing.T) {
+	conn := listenUDP(t)
+
+	w, err := Dial("udp", conn.LocalAddr().String(), WithChunkSize(chunkHeaderSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	if _, err := w.Write(make([]byte, MaxChunks+1)); err != ErrMessageTooLarge {
+		t.Errorf("got error %v, want %v", err, ErrMessageTooLarge)
+	}
+
+	if _, err := w.Write(make([]byte, MaxChunks)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestWriter_tcp(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	w, err := Dial("tcp", ln.Addr().String(), WithCompression(CompressGzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	l := NewLogger(w, log.WithFilterLevel(level.All))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	l.Debug("msg", "first")
+	l.Error("msg", "second")
+
+	r := bufio.NewReader(conn)
+
+	for _, want := range []string{`"short_message":"first"`, `"short_message":"second"`} {
+		got, err := r.ReadString(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(got, "{") || !strings.HasSuffix(got, "}\x00") || !strings.Contains(got, want) {
+			t.Errorf("got %q, want a null-terminated object containing %q", got, want)
+		}
+	}
+}
+
+func TestWriter_closed(t *testing.T) {
+	conn := listenUDP(t)
+
+	w, err := Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("{}\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close: got %v, want %v", err, ErrClosed)
+	}
+
+	if err := w.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Close after Close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestDial_error(t *testing.T) {
+	if _, err := Dial("unix", "/dev/log"); err == nil {
+		t.Error("expected an error for an unsupported network")
+	}
+
+	if _, err := Dial("udp", "127.0.0.1:12201", WithChunkSize(chunkHeaderSize)); err == nil {
+		t.Error("expected an error for a chunk size without room for data")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, err := Dial("tcp", addr); err == nil {
+		t.Error("expected an error for a closed port")
+	}
+}
@@ -0,0 +1,233 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package journald sends log messages to the systemd journal using its native
+// protocol, so that each field of the messages becomes a journal field.  Use
+// NewLogger to create a LeveledLogger sending messages to a Writer returned by
+// Dial:
+//
+//	w, err := journald.Dial()
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	logger := journald.NewLogger(w, log.WithFilterLevel(level.All))
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// Journal fields set by the Encoder.
+const (
+	FieldMessage          = "MESSAGE"
+	FieldPriority         = "PRIORITY"
+	FieldSyslogIdentifier = "SYSLOG_IDENTIFIER"
+)
+
+// Priorities used for the levels of the messages.  Trace messages have the
+// debug priority, like Debug messages.
+const (
+	priorityError = "3"
+	priorityInfo  = "6"
+	priorityDebug = "7"
+)
+
+// maxFieldName is the maximum length of journal field names.
+const maxFieldName = 64
+
+// config holds the settings of the Encoder and Writer.
+type config struct {
+	identifier string
+	messageKey string
+	socket     string
+}
+
+// Option configures an Encoder, or the Encoder and Writer returned by Dial.
+type Option func(*config)
+
+// WithSyslogIdentifier returns an Option that sets the SYSLOG_IDENTIFIER field
+// of the messages, the base name of the executable by default.
+func WithSyslogIdentifier(id string) Option {
+	return func(c *config) {
+		c.identifier = id
+	}
+}
+
+// WithMessageKey returns an Option that sets the key of the field used as the
+// MESSAGE field, log.MessageKey by default.  It must match the name given to
+// log.WithKeyNames, if any.
+func WithMessageKey(key string) Option {
+	return func(c *config) {
+		c.messageKey = key
+	}
+}
+
+// WithSocket returns an Option that sets the path of the socket of journald
+// used by Dial, DefaultSocket by default.
+func WithSocket(path string) Option {
+	return func(c *config) {
+		c.socket = path
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{messageKey: log.MessageKey, socket: DefaultSocket}
+
+	if len(os.Args) > 0 {
+		c.identifier = filepath.Base(os.Args[0])
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// Encoder is a log.Encoder formatting messages with the native protocol of
+// journald.  The field with the log.MessageKey key is written as the MESSAGE
+// field, and the level as the PRIORITY field.  The keys of the other fields are
+// converted to journal field names: they are uppercased, characters other than
+// letters, digits and underscores are replaced with underscores, and leading
+// underscores are removed.  Keys that would conflict with the fields set by the
+// Encoder are prefixed with log.ReservedKeyPrefix, e.g. "FIELDS_PRIORITY".
+type Encoder struct {
+	config
+}
+
+// NewEncoder returns a new Encoder.  Its defaults can be changed by providing
+// Options.
+func NewEncoder(opts ...Option) *Encoder {
+	return &Encoder{newConfig(opts)}
+}
+
+// Encode implements the log.Encoder interface.
+func (enc *Encoder) Encode(e *log.Entry) ([]byte, error) {
+	var b bytes.Buffer
+
+	writeField(&b, FieldPriority, priority(e.Level))
+
+	if enc.identifier != "" {
+		writeField(&b, FieldSyslogIdentifier, enc.identifier)
+	}
+
+	hasMessage := false
+
+	for _, item := range logmap.FromKeyvals(e.Keyvals...) {
+		value, err := logmap.TextFromValue(item.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Key == enc.messageKey && !hasMessage {
+			writeField(&b, FieldMessage, value)
+			hasMessage = true
+
+			continue
+		}
+
+		writeField(&b, fieldName(item.Key), value)
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeField writes a field of the native protocol.  Values holding newlines
+// are written in the binary form, preceded by their length.
+func writeField(b *bytes.Buffer, name, value string) {
+	b.WriteString(name)
+
+	if !strings.ContainsRune(value, '\n') {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+
+		return
+	}
+
+	var size [8]byte
+
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+
+	b.WriteByte('\n')
+	b.Write(size[:])
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// priority returns the journal priority of the level.
+func priority(lvl level.Level) string {
+	switch lvl {
+	case level.Error:
+		return priorityError
+	case level.Info:
+		return priorityInfo
+	default:
+		return priorityDebug
+	}
+}
+
+// reservedPrefix is log.ReservedKeyPrefix converted to a field name.
+var reservedPrefix = sanitize(log.ReservedKeyPrefix)
+
+// fieldName converts the key to a journal field name.
+func fieldName(key string) string {
+	name := sanitize(key)
+
+	switch name {
+	case FieldMessage, FieldPriority, FieldSyslogIdentifier:
+		name = reservedPrefix + name
+	case "":
+		name = "FIELD"
+	}
+
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "F" + name
+	}
+
+	if len(name) > maxFieldName {
+		name = name[:maxFieldName]
+	}
+
+	return name
+}
+
+// sanitize uppercases the key, replaces the characters that are not allowed in
+// journal field names with underscores and removes leading underscores.
+func sanitize(key string) string {
+	key = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+
+	return strings.TrimLeft(key, "_")
+}
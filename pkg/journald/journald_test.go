@@ -0,0 +1,74 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package journald
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+type failingTextMarshaler struct{}
+
+func (failingTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("marshal failure")
+}
+
+func TestEncoder(t *testing.T) {
+	tests := map[string]struct {
+		opts  []Option
+		entry log.Entry
+		want  string
+	}{
+		"fields": {
+			[]Option{WithSyslogIdentifier("server")},
+			log.Entry{Level: level.Info, Keyvals: []interface{}{"msg", "server started", "http.port", 8080, "_pid", 1}},
+			"PRIORITY=6\nSYSLOG_IDENTIFIER=server\nMESSAGE=server started\nHTTP_PORT=8080\nPID=1\n",
+		},
+		"reserved": {
+			[]Option{WithSyslogIdentifier(""), WithMessageKey("message")},
+			log.Entry{Level: level.Error, Keyvals: []interface{}{"priority", "high", "message", "failed", "msg", "other", "2fa", true, "", 1}},
+			"PRIORITY=3\nFIELDS_PRIORITY=high\nMESSAGE=failed\nMSG=other\nF2FA=true\nFIELD=1\n",
+		},
+		"multiline": {
+			[]Option{WithSyslogIdentifier("")},
+			log.Entry{Level: level.Trace, Keyvals: []interface{}{"msg", "a\nb", "stack", "c\n"}},
+			"PRIORITY=7\nMESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\nSTACK\n\x02\x00\x00\x00\x00\x00\x00\x00c\n\n",
+		},
+	}
+
+	for name, tc := range tests {
+		tc.entry.Time = time.Now()
+
+		got, err := NewEncoder(tc.opts...).Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+
+	_, err := NewEncoder().Encode(&log.Entry{Keyvals: []interface{}{"key", failingTextMarshaler{}}})
+	if err == nil {
+		t.Error("expected an error for a failing TextMarshaler")
+	}
+}
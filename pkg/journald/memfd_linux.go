@@ -0,0 +1,129 @@
+//go:build linux
+// +build linux
+
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package journald
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Flags of memfd_create and seals of fcntl, which the syscall package lacks.
+const (
+	mfdCloexec       = 0x1
+	mfdAllowSealing  = 0x2
+	fcntlAddSeals    = 1033
+	fcntlSealSeal    = 0x1
+	fcntlSealShrink  = 0x2
+	fcntlSealGrow    = 0x4
+	fcntlSealWrite   = 0x8
+	fcntlSealAllSeal = fcntlSealSeal | fcntlSealShrink | fcntlSealGrow | fcntlSealWrite
+)
+
+// memfdCreateTrap holds the number of the memfd_create system call, which the
+// syscall package does not define for all architectures.
+var memfdCreateTrap = map[string]uintptr{
+	"386":     356,
+	"amd64":   319,
+	"arm":     385,
+	"arm64":   279,
+	"ppc64le": 360,
+	"riscv64": 279,
+	"s390x":   350,
+}
+
+// isTooLarge reports whether sending a datagram failed because it was too
+// large.
+func isTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendLarge sends the message by writing it to a sealed memory file, or to an
+// unlinked temporary file in /dev/shm if memory files are not supported, and
+// sending its descriptor to journald.
+func sendLarge(conn *net.UnixConn, addr *net.UnixAddr, p []byte) error {
+	f, err := memfd(p)
+	if err != nil {
+		f, err = tempFile(p)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), addr)
+
+	return err
+}
+
+// memfd returns a sealed memory file holding p.
+func memfd(p []byte) (*os.File, error) {
+	trap, ok := memfdCreateTrap[runtime.GOARCH]
+	if !ok {
+		return nil, syscall.ENOSYS
+	}
+
+	name := []byte("journald\x00")
+
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(&name[0])), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	f := os.NewFile(fd, "journald")
+
+	if _, err := f.Write(p); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, fcntlAddSeals, fcntlSealAllSeal); errno != 0 {
+		f.Close()
+
+		return nil, errno
+	}
+
+	return f, nil
+}
+
+// tempFile returns an unlinked temporary file in /dev/shm holding p.
+func tempFile(p []byte) (*os.File, error) {
+	f, err := ioutil.TempFile("/dev/shm", "journald")
+	if err != nil {
+		return nil, err
+	}
+
+	os.Remove(f.Name())
+
+	if _, err := f.Write(p); err != nil {
+		f.Close()
+
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,94 @@
+//go:build linux
+// +build linux
+
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package journald
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestWriter_large(t *testing.T) {
+	conn, path := listen(t)
+
+	w, err := Dial(WithSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	// Make the payload too large for a datagram.
+	if err := w.conn.SetWriteBuffer(4096); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := bytes.Repeat([]byte("MESSAGE=large\n"), 10000)
+
+	if _, err := w.Write(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	oob := make([]byte, syscall.CmsgSpace(4)) //nolint:gomnd // one file descriptor
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Errorf("got %d bytes of data, want 0", n)
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("got control messages %v, %v", msgs, err)
+	}
+
+	fds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("got file descriptors %v, %v", fds, err)
+	}
+
+	f := os.NewFile(uintptr(fds[0]), "payload")
+	defer f.Close()
+
+	got := make([]byte, len(payload)+1)
+
+	n, _ = f.ReadAt(got, 0)
+	if !bytes.Equal(got[:n], payload) {
+		t.Errorf("got %d bytes, want the %d bytes of the payload", n, len(payload))
+	}
+
+	// Memory files are sealed against writes.
+	if _, err := f.WriteAt([]byte("x"), 0); err == nil && isMemfd(f) {
+		t.Error("expected a sealed memory file")
+	}
+}
+
+// isMemfd reports whether f is a memory file rather than a file in /dev/shm.
+func isMemfd(f *os.File) bool {
+	target, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(f.Fd())))
+
+	return err == nil && bytes.HasPrefix([]byte(target), []byte("/memfd:"))
+}
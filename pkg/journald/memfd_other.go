@@ -0,0 +1,40 @@
+//go:build !linux
+// +build !linux
+
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package journald
+
+import (
+	"errors"
+	"net"
+)
+
+// errTooLarge is returned when a message is too large to be sent in a single
+// datagram, which is only supported on Linux.
+var errTooLarge = errors.New("journald: message too large")
+
+// isTooLarge always reports false, as large messages are only supported on
+// Linux.
+func isTooLarge(err error) bool {
+	return false
+}
+
+// sendLarge is never called on platforms other than Linux.
+func sendLarge(conn *net.UnixConn, addr *net.UnixAddr, p []byte) error {
+	return errTooLarge
+}
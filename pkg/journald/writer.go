@@ -0,0 +1,99 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package journald
+
+import (
+	"net"
+	"os"
+	"sync"
+
+	"github.com/cobaltspeech/log"
+)
+
+// DefaultSocket is the path of the socket of journald for the native protocol.
+const DefaultSocket = "/run/systemd/journal/socket"
+
+// Writer is an io.Writer sending each write to journald as a message of the
+// native protocol.  It is meant to be used by a LeveledLogger created by
+// NewLogger, which writes the messages formatted by the Encoder of the Writer.
+//
+// Messages too large to be sent in a single datagram are written to a sealed
+// memory file on Linux, whose descriptor is sent to journald instead.  A Writer
+// is safe for concurrent use.
+type Writer struct {
+	encoder *Encoder
+
+	// conn is not connected, as file descriptors cannot be sent over connected
+	// datagram sockets; messages are sent to addr.
+	addr *net.UnixAddr
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+// Dial returns a new Writer sending messages to the socket of journald.  The
+// Options configure the socket and the format of the messages, see NewEncoder.
+func Dial(opts ...Option) (*Writer, error) {
+	enc := NewEncoder(opts...)
+
+	if _, err := os.Stat(enc.socket); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{encoder: enc, addr: &net.UnixAddr{Name: enc.socket, Net: "unixgram"}, conn: conn}, nil
+}
+
+// NewLogger returns a new LeveledLogger sending its messages to w, formatted
+// by the Encoder of w.  The Options are applied before those setting the
+// output and the Encoder.
+func NewLogger(w *Writer, opts ...log.Option) *log.LeveledLogger {
+	opts = append(opts, log.WithOutput(w), log.WithEncoder(w.Encoder()), log.WithTimestamp(log.TimestampNone))
+
+	return log.NewLeveledLogger(opts...)
+}
+
+// Encoder returns the Encoder formatting the messages sent by w.
+func (w *Writer) Encoder() *Encoder {
+	return w.encoder
+}
+
+// Write sends p as a message.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, _, err := w.conn.WriteMsgUnix(p, nil, w.addr)
+	if err != nil && isTooLarge(err) {
+		err = sendLarge(w.conn, w.addr, p)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the socket.
+func (w *Writer) Close() error {
+	return w.conn.Close()
+}
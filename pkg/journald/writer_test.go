@@ -0,0 +1,86 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package journald
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// listen returns a fake journald socket.
+func listen(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "socket")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets not supported: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, path
+}
+
+func TestWriter(t *testing.T) {
+	conn, path := listen(t)
+
+	w, err := Dial(WithSocket(path), WithSyslogIdentifier("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	NewLogger(w).Info("msg", "hello", "n", 1)
+
+	buf := make([]byte, 1024)
+
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "PRIORITY=6\nSYSLOG_IDENTIFIER=test\nMESSAGE=hello\nN=1\n"
+	if got := string(buf[:n]); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDial_error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if _, err := Dial(WithSocket(filepath.Join(dir, "missing"))); err == nil {
+		t.Error("expected an error for a missing socket")
+	}
+}
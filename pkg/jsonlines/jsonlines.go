@@ -0,0 +1,645 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package jsonlines streams log messages as newline-delimited JSON to a
+// collector over TCP, optionally with TLS.  Use NewLogger to create a
+// LeveledLogger sending messages to a Writer returned by Dial:
+//
+//	w, err := jsonlines.Dial("collector.example.com:5170", jsonlines.WithSpool("/var/spool/server"))
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	logger := jsonlines.NewLogger(w, log.WithFilterLevel(level.All))
+package jsonlines
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cobaltspeech/log"
+)
+
+// LevelKey is the key of the level of the messages written by the LeveledLogger
+// returned by NewLogger.
+const LevelKey = "level"
+
+// SpoolFile is the name of the file holding the spooled messages in the
+// directory given to WithSpool.
+const SpoolFile = "spool.jsonl"
+
+// Defaults of the Options.
+const (
+	DefaultQueueSize  = 1024
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Timeouts of the connection to the collector.
+const (
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// spoolChunk is the size of the chunks of the spool read at once.
+const spoolChunk = 64 << 10
+
+// spoolTempSuffix is the suffix of the temporary files replacing the spool.
+const spoolTempSuffix = ".tmp"
+
+// ErrClosed is returned by Write after Close.
+var ErrClosed = errors.New("jsonlines: writer closed")
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// WithTLS returns an Option that connects to the collector with TLS.
+func WithTLS(config *tls.Config) Option {
+	return func(w *Writer) {
+		w.tls = config
+	}
+}
+
+// WithQueueSize returns an Option that sets the number of messages held in
+// memory while they are sent, DefaultQueueSize by default.  Messages written
+// while the queue is full are dropped, unless a spool is used.
+func WithQueueSize(n int) Option {
+	return func(w *Writer) {
+		w.queueSize = n
+	}
+}
+
+// WithBackoff returns an Option that sets the delays between attempts to
+// connect to the collector, which double after each failed attempt from min
+// up to max.
+func WithBackoff(min, max time.Duration) Option {
+	return func(w *Writer) {
+		w.minBackoff = min
+		w.maxBackoff = max
+	}
+}
+
+// WithSpool returns an Option that persists the messages written while the
+// collector is unreachable to the SpoolFile of the given directory, which is
+// created if needed.  The spooled messages are sent in order once the
+// collector is reachable again, before any newer message, including messages
+// spooled by a previous Writer.
+func WithSpool(dir string) Option {
+	return func(w *Writer) {
+		w.spoolDir = dir
+	}
+}
+
+// WithMaxSpoolSize returns an Option that limits the size of the spool, in
+// bytes.  New messages that do not fit are dropped, and when the queued
+// messages are moved to the spool, the oldest messages are dropped to make
+// room for them.
+func WithMaxSpoolSize(bytes int64) Option {
+	return func(w *Writer) {
+		w.maxSpoolSize = bytes
+	}
+}
+
+// Writer is an io.Writer sending each write to a collector as a line.  Writes
+// do not wait for the network: lines are queued in memory, or in the spool
+// while the collector is unreachable, and sent by a separate goroutine which
+// reconnects with an exponential backoff.
+//
+// A Writer is safe for concurrent use.
+type Writer struct {
+	addr         string
+	tls          *tls.Config
+	queueSize    int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	spoolDir     string
+	maxSpoolSize int64
+
+	queue   chan []byte
+	done    chan struct{}
+	stopped chan struct{}
+	dropped uint64
+
+	// mu protects the fields below.  While spooling is true, lines are
+	// appended to the spool rather than queued, which keeps them in order.
+	mu        sync.Mutex
+	closed    bool
+	spooling  bool
+	spool     *os.File
+	spoolSize int64
+}
+
+// Dial returns a new Writer sending lines to the collector at the given
+// address.  Dial does not wait for the connection to the collector, and only
+// fails if the spool cannot be opened.
+func Dial(addr string, opts ...Option) (*Writer, error) {
+	w := &Writer{
+		addr:       addr,
+		queueSize:  DefaultQueueSize,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.queue = make(chan []byte, w.queueSize)
+
+	if w.spoolDir != "" {
+		if err := w.openSpool(); err != nil {
+			return nil, err
+		}
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// NewLogger returns a new LeveledLogger writing its messages to w as JSON
+// objects, with the level as a LevelKey field and the timestamp as a
+// log.TimeKey field.  The Options are applied before those setting the output,
+// the Encoder and the placement of the timestamp.
+func NewLogger(w *Writer, opts ...log.Option) *log.LeveledLogger {
+	opts = append(opts,
+		log.WithOutput(w),
+		log.WithEncoder(log.NewJSONEncoder(log.WithLevelKey(LevelKey))),
+		log.WithTimestamp(log.TimestampField))
+
+	return log.NewLeveledLogger(opts...)
+}
+
+// Write queues p to be sent as a line, adding a trailing newline if needed.
+func (w *Writer) Write(p []byte) (int, error) {
+	line := make([]byte, len(p), len(p)+1)
+	copy(line, p)
+
+	if !bytes.HasSuffix(line, []byte{'\n'}) {
+		line = append(line, '\n')
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	if w.spooling {
+		return len(p), w.appendSpool(line)
+	}
+
+	select {
+	case w.queue <- line:
+	default:
+		if w.spool == nil {
+			atomic.AddUint64(&w.dropped, 1)
+
+			break
+		}
+
+		// The spool keeps the lines in order until the queue is drained.
+		w.spooling = true
+
+		return len(p), w.appendSpool(line)
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of lines dropped because the queue or the spool
+// was full.
+func (w *Writer) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Close stops sending lines.  The queued lines are sent if the collector is
+// reachable, and spooled otherwise, or dropped if no spool is used.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+
+		return ErrClosed
+	}
+
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	<-w.stopped
+
+	if w.spool != nil {
+		return w.spool.Close()
+	}
+
+	return nil
+}
+
+// run sends the queued and spooled lines until the Writer is closed.
+func (w *Writer) run() {
+	defer close(w.stopped)
+
+	var (
+		conn    net.Conn
+		pending []byte
+		backoff = w.minBackoff
+	)
+
+	for {
+		if conn == nil {
+			var err error
+
+			if conn, err = w.dial(); err != nil {
+				conn = nil
+
+				// Persist the queued lines while the collector is unreachable.
+				w.spoolQueue(&pending)
+
+				select {
+				case <-time.After(backoff):
+				case <-w.done:
+					w.drain(nil, pending)
+
+					return
+				}
+
+				if backoff *= 2; backoff > w.maxBackoff {
+					backoff = w.maxBackoff
+				}
+
+				continue
+			}
+
+			backoff = w.minBackoff
+		}
+
+		if pending != nil {
+			if err := send(conn, pending); err != nil {
+				conn = w.failed(conn, &pending)
+
+				continue
+			}
+
+			pending = nil
+		}
+
+		// Queued lines are older than spooled lines, so the queue is drained
+		// before the spool is replayed.
+		select {
+		case pending = <-w.queue:
+			continue
+		default:
+		}
+
+		if err := w.replaySpool(conn); err != nil {
+			conn = w.failed(conn, nil)
+
+			continue
+		}
+
+		select {
+		case pending = <-w.queue:
+		case <-w.done:
+			w.drain(conn, nil)
+			conn.Close()
+
+			return
+		}
+	}
+}
+
+// dial connects to the collector.
+func (w *Writer) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	if w.tls != nil {
+		return tls.DialWithDialer(dialer, "tcp", w.addr, w.tls)
+	}
+
+	return dialer.Dial("tcp", w.addr)
+}
+
+// send writes the lines to the collector.
+func send(conn net.Conn, lines []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	_, err := conn.Write(lines)
+
+	return err
+}
+
+// failed closes the connection after a failed write, and spools the queued
+// lines.  It returns nil.
+func (w *Writer) failed(conn net.Conn, pending *[]byte) net.Conn {
+	conn.Close()
+	w.spoolQueue(pending)
+
+	return nil
+}
+
+// spoolQueue moves the pending line, if not nil, and the queued lines to the
+// spool, before the lines already spooled, which are newer.  New lines are
+// spooled until the spool is replayed.  It does nothing if no spool is used.
+func (w *Writer) spoolQueue(pending *[]byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.spool == nil {
+		return
+	}
+
+	w.spooling = true
+
+	var lines [][]byte
+
+	if pending != nil && *pending != nil {
+		lines = append(lines, *pending)
+		*pending = nil
+	}
+
+	for len(w.queue) > 0 {
+		lines = append(lines, <-w.queue)
+	}
+
+	w.prependSpool(lines)
+}
+
+// drain handles the queued lines when the Writer is closed: they are sent
+// over conn if not nil, and spooled or dropped otherwise.
+func (w *Writer) drain(conn net.Conn, pending []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lines := [][]byte{}
+	if pending != nil {
+		lines = append(lines, pending)
+	}
+
+	for len(w.queue) > 0 {
+		lines = append(lines, <-w.queue)
+	}
+
+	for i, line := range lines {
+		if conn == nil || w.spooling {
+			w.prependSpool(lines[i:])
+
+			return
+		}
+
+		if err := send(conn, line); err != nil {
+			conn = nil
+			w.prependSpool(lines[i:])
+
+			return
+		}
+	}
+}
+
+// openSpool opens the spool, which may hold lines spooled by a previous
+// Writer.
+func (w *Writer) openSpool() error {
+	if err := os.MkdirAll(w.spoolDir, 0o755); err != nil { //nolint:gomnd // rwxr-xr-x
+		return err
+	}
+
+	// Remove the temporary files left by a Writer that crashed while
+	// rewriting the spool, which still holds all its lines.
+	stale, _ := filepath.Glob(filepath.Join(w.spoolDir, SpoolFile+".*"+spoolTempSuffix))
+	for _, name := range stale {
+		os.Remove(name)
+	}
+
+	f, err := os.OpenFile(filepath.Join(w.spoolDir, SpoolFile), os.O_CREATE|os.O_RDWR, 0o644) //nolint:gomnd // rw-r--r--
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	w.spool = f
+	w.spoolSize = info.Size()
+	w.spooling = w.spoolSize > 0
+
+	return nil
+}
+
+// appendSpool appends the line to the spool.  It must be called with the lock
+// held.
+func (w *Writer) appendSpool(line []byte) error {
+	if w.maxSpoolSize > 0 && w.spoolSize+int64(len(line)) > w.maxSpoolSize {
+		atomic.AddUint64(&w.dropped, 1)
+
+		return nil
+	}
+
+	n, err := w.spool.WriteAt(line, w.spoolSize)
+	w.spoolSize += int64(n)
+
+	return err
+}
+
+// prependSpool inserts the lines at the start of the spool, or drops them if no
+// spool is used.  It must be called with the lock held.
+func (w *Writer) prependSpool(lines [][]byte) {
+	if len(lines) == 0 {
+		return
+	}
+
+	if w.spool == nil {
+		atomic.AddUint64(&w.dropped, uint64(len(lines)))
+
+		return
+	}
+
+	w.spooling = true
+
+	if err := w.rewriteSpool(lines, 0); err != nil {
+		atomic.AddUint64(&w.dropped, uint64(len(lines)))
+	}
+}
+
+// replaySpool sends the spooled lines over conn, then truncates the spool and
+// stops spooling, so that new lines are queued again.
+func (w *Writer) replaySpool(conn net.Conn) error {
+	var offset int64
+
+	for {
+		w.mu.Lock()
+
+		if !w.spooling {
+			w.mu.Unlock()
+
+			return nil
+		}
+
+		if offset >= w.spoolSize {
+			// The spool was fully sent: queue new lines again.
+			err := w.spool.Truncate(0)
+			w.spoolSize = 0
+			w.spooling = false
+			w.mu.Unlock()
+
+			return err
+		}
+
+		chunk := make([]byte, spoolChunk)
+
+		n, err := w.spool.ReadAt(chunk, offset)
+		w.mu.Unlock()
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		// Only send complete lines; a line longer than the chunk is sent in
+		// parts.
+		chunk = chunk[:n]
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			chunk = chunk[:i+1]
+		}
+
+		if err := send(conn, chunk); err != nil {
+			w.mu.Lock()
+			w.dropSpooled(offset)
+			w.mu.Unlock()
+
+			return err
+		}
+
+		offset += int64(len(chunk))
+	}
+}
+
+// dropSpooled removes the first n bytes of the spool, which were sent.  It
+// must be called with the lock held.
+func (w *Writer) dropSpooled(n int64) {
+	if n == 0 {
+		return
+	}
+
+	_ = w.rewriteSpool(nil, n)
+}
+
+// rewriteSpool replaces the spool with the lines followed by the spooled bytes
+// starting at offset from.  If the result is larger than the maximum spool
+// size, the oldest lines are dropped, starting with the given lines.  The new
+// spool is written to a temporary file, which is synced and renamed over the
+// spool, so that a crash never leaves a partial spool.  The spool is kept
+// unchanged if rewriting it fails.  It must be called with the lock held.
+func (w *Writer) rewriteSpool(lines [][]byte, from int64) (err error) {
+	size := w.spoolSize - from
+	for _, line := range lines {
+		size += int64(len(line))
+	}
+
+	tmp, err := ioutil.TempFile(w.spoolDir, SpoolFile+".*"+spoolTempSuffix)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	var dropped uint64
+
+	bw := bufio.NewWriter(tmp)
+
+	for _, line := range lines {
+		if w.maxSpoolSize > 0 && size > w.maxSpoolSize {
+			size -= int64(len(line))
+			dropped++
+
+			continue
+		}
+
+		if _, err := bw.Write(line); err != nil {
+			return err
+		}
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(w.spool, from, w.spoolSize-from))
+
+	for w.maxSpoolSize > 0 && size > w.maxSpoolSize {
+		line, err := r.ReadSlice('\n')
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil && err != bufio.ErrBufferFull {
+			return err
+		}
+
+		size -= int64(len(line))
+
+		if err == nil {
+			dropped++
+		}
+	}
+
+	if _, err := io.Copy(bw, r); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	if err := tmp.Chmod(0o644); err != nil { //nolint:gomnd // rw-r--r--
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(w.spoolDir, SpoolFile)); err != nil {
+		return err
+	}
+
+	w.spool.Close()
+	w.spool = tmp
+	w.spoolSize = size
+	atomic.AddUint64(&w.dropped, dropped)
+
+	return nil
+}
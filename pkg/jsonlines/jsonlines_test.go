@@ -0,0 +1,316 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package jsonlines
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unusedAddr returns the address of a closed listener.
+func unusedAddr(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	return addr
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "jsonlines")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+// accept returns a reader of the lines of the next connection to ln.
+func accept(t *testing.T, ln net.Listener) *bufio.Reader {
+	t.Helper()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	return bufio.NewReader(conn)
+}
+
+func readLines(t *testing.T, r *bufio.Reader, want ...string) {
+	t.Helper()
+
+	for _, w := range want {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading %q: %v", w, err)
+		}
+
+		if line != w+"\n" {
+			t.Fatalf("got line %q, want %q", line, w)
+		}
+	}
+}
+
+// waitSpooled waits until the spool holds n lines.
+func waitSpooled(t *testing.T, dir string, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		b, _ := ioutil.ReadFile(filepath.Join(dir, SpoolFile))
+		if strings.Count(string(b), "\n") == n {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("got spool %q, want %d lines", b, n)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	w, err := Dial(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	NewLogger(w).Info("msg", "hello", "n", 1)
+
+	line, err := accept(t, ln).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got[LevelKey] != "info" || got["msg"] != "hello" || got["n"] != "1" || got["ts"] == "" {
+		t.Errorf("unexpected line %q", line)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != ErrClosed {
+		t.Errorf("second Close: got %v, want %v", err, ErrClosed)
+	}
+
+	if _, err := w.Write([]byte("x")); err != ErrClosed {
+		t.Errorf("Write after Close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestWriter_spool(t *testing.T) {
+	addr := unusedAddr(t)
+	dir := tempDir(t)
+
+	w, err := Dial(addr, WithSpool(dir), WithBackoff(time.Millisecond, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(w, "line %d", i)
+	}
+
+	waitSpooled(t, dir, 5)
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s again: %v", addr, err)
+	}
+
+	defer ln.Close()
+
+	r := accept(t, ln)
+	readLines(t, r, "line 0", "line 1", "line 2", "line 3", "line 4")
+
+	fmt.Fprintf(w, "line 5\n")
+	readLines(t, r, "line 5")
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitSpooled(t, dir, 0)
+}
+
+func TestWriter_spoolReplayedByNextWriter(t *testing.T) {
+	dir := tempDir(t)
+
+	w, err := Dial(unusedAddr(t), WithSpool(dir), WithMaxSpoolSize(14))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Fprintf(w, "old 0")
+	fmt.Fprintf(w, "old 1")
+	fmt.Fprintf(w, "old 2")
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Dropped() != 1 {
+		t.Errorf("got %d dropped, want 1", w.Dropped())
+	}
+
+	waitSpooled(t, dir, 2)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	w, err = Dial(ln.Addr().String(), WithSpool(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	fmt.Fprintf(w, "new 0")
+
+	// Depending on whether the lines were queued before the first connection
+	// failed, the newest or the oldest line was dropped.
+	r := accept(t, ln)
+
+	first, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == "old 0\n" {
+		readLines(t, r, "old 1", "new 0")
+	} else {
+		readLines(t, r, "old 2", "new 0")
+	}
+}
+
+func TestWriter_prependSpool(t *testing.T) {
+	dir := tempDir(t)
+	w := &Writer{spoolDir: dir, maxSpoolSize: 18}
+
+	if err := w.openSpool(); err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.spool.Close()
+
+	if err := w.appendSpool([]byte("new 0\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	w.prependSpool([][]byte{[]byte("old 0\n"), []byte("old 1\n"), []byte("old 2\n")})
+	waitSpooled(t, dir, 3)
+
+	if w.Dropped() != 1 {
+		t.Errorf("got %d dropped, want 1", w.Dropped())
+	}
+
+	w.dropSpooled(6)
+	w.maxSpoolSize = 12
+	w.prependSpool([][]byte{[]byte("older\n")})
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, SpoolFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "old 2\nnew 0\n" || w.spoolSize != int64(len(b)) {
+		t.Errorf("got spool %q of size %d, want the newest lines", b, w.spoolSize)
+	}
+
+	if w.Dropped() != 2 {
+		t.Errorf("got %d dropped, want 2", w.Dropped())
+	}
+
+	w.maxSpoolSize = 6
+	w.prependSpool([][]byte{[]byte("x\n")})
+	waitSpooled(t, dir, 1)
+
+	if w.Dropped() != 4 {
+		t.Errorf("got %d dropped, want 4", w.Dropped())
+	}
+
+	if names, _ := filepath.Glob(filepath.Join(dir, "*")); len(names) != 1 {
+		t.Errorf("got files %v, want only the spool", names)
+	}
+}
+
+func TestWriter_dropped(t *testing.T) {
+	w, err := Dial(unusedAddr(t), WithQueueSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(w, "line %d", i)
+	}
+
+	if w.Dropped() != 3 {
+		t.Errorf("got %d dropped, want 3", w.Dropped())
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Dropped() != 5 {
+		t.Errorf("got %d dropped after Close, want 5", w.Dropped())
+	}
+}
@@ -0,0 +1,463 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package logfile provides a Writer for log files that are rotated by size or
+// age, e.g. to be used with log.WithOutput on systems without logrotate.
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is the format of the time of rotation included in the
+// names of backup files.  It sorts in chronological order.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressSuffix is the suffix added to the names of compressed backups.
+const compressSuffix = ".gz"
+
+// Writer is an io.Writer appending to a file, which is renamed to a backup
+// file and replaced by an empty file when it reaches its maximum size or age.
+// Backup files are named after the file and the time of rotation, e.g.
+// "server-2021-03-04T10-06-07.890.log" for "server.log".
+//
+// A Writer is safe for concurrent use.
+type Writer struct {
+	path string
+
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+	sighup     bool
+	now        func() time.Time
+
+	// file is nil after a failed rotation, until the next Write opens it
+	// again.
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+
+	// opened is the time the file was opened, or first written if it was
+	// empty, and is used by WithInterval.
+	opened time.Time
+
+	// mill serializes the compression and removal of backups, which are done
+	// in the background by goroutines tracked by milling.
+	mill    sync.Mutex
+	milling sync.WaitGroup
+
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// WithMaxSize returns an Option that rotates the file before a write would
+// make it larger than the given number of bytes.
+func WithMaxSize(bytes int64) Option {
+	return func(w *Writer) {
+		w.maxSize = bytes
+	}
+}
+
+// WithInterval returns an Option that rotates the file before a write if it
+// was first written more than the given duration before.
+func WithInterval(d time.Duration) Option {
+	return func(w *Writer) {
+		w.interval = d
+	}
+}
+
+// WithMaxBackups returns an Option that removes the oldest backups when there
+// are more than n of them.
+func WithMaxBackups(n int) Option {
+	return func(w *Writer) {
+		w.maxBackups = n
+	}
+}
+
+// WithMaxAge returns an Option that removes the backups rotated more than the
+// given duration ago.
+func WithMaxAge(d time.Duration) Option {
+	return func(w *Writer) {
+		w.maxAge = d
+	}
+}
+
+// WithCompression returns an Option that compresses backups with gzip, in the
+// background, adding a ".gz" suffix to their names.
+func WithCompression() Option {
+	return func(w *Writer) {
+		w.compress = true
+	}
+}
+
+// WithReopenOnSIGHUP returns an Option that calls Reopen when the process
+// receives SIGHUP, e.g. after the file was moved by an external tool.
+func WithReopenOnSIGHUP() Option {
+	return func(w *Writer) {
+		w.sighup = true
+	}
+}
+
+// Open returns a new Writer appending to the file at path, which is created
+// with its directory if needed.  Files are never rotated unless WithMaxSize or
+// WithInterval is provided, and backups are kept forever unless WithMaxBackups
+// or WithMaxAge is provided.
+func Open(path string, opts ...Option) (*Writer, error) {
+	w := &Writer{path: path, now: time.Now}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	if w.sighup {
+		w.signals = make(chan os.Signal, 1)
+		w.done = make(chan struct{})
+		signal.Notify(w.signals, syscall.SIGHUP)
+
+		go w.handleSignals()
+	}
+
+	return w, nil
+}
+
+// Write writes p to the file, rotating it first if needed.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	switch {
+	case w.size == 0:
+		// Empty files are not rotated, and their age is counted from their
+		// first write.
+		w.opened = w.now()
+	case w.needsRotation(int64(len(p))):
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Rotate renames the file to a backup file and opens a new file.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	return w.rotate()
+}
+
+// Reopen closes and reopens the file, which is created if it was moved or
+// removed since it was opened.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	return w.open()
+}
+
+// Close closes the file, and waits until the background compression and
+// removal of backups are done.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+
+	w.closed = true
+
+	if w.signals != nil {
+		signal.Stop(w.signals)
+		close(w.done)
+	}
+
+	err := w.closeFile()
+
+	w.milling.Wait()
+
+	return err
+}
+
+func (w *Writer) handleSignals() {
+	for {
+		select {
+		case <-w.signals:
+			_ = w.Reopen()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// needsRotation reports whether the file must be rotated before writing n
+// bytes.  It must be called with the lock held.
+func (w *Writer) needsRotation(n int64) bool {
+	if w.maxSize > 0 && w.size+n > w.maxSize {
+		return true
+	}
+
+	return w.interval > 0 && w.now().Sub(w.opened) >= w.interval
+}
+
+// open opens the file for appending.  It must be called with the lock held.
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil { //nolint:gomnd // rwxr-xr-x
+		return err
+	}
+
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:gomnd // rw-r--r--
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	w.file = f
+	w.size = info.Size()
+	w.opened = w.now()
+
+	return nil
+}
+
+// closeFile closes the file, if open.  The file cannot be used after Close,
+// even if it failed, so the next Write opens it again.  It must be called with
+// the lock held.
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
+
+// rotate renames the file to a backup and opens a new file.  If closing the
+// file fails, it is not renamed, and the next Write opens it again.  It must
+// be called with the lock held.
+func (w *Writer) rotate() error {
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+
+	now := w.now()
+
+	backup := w.backupName(now)
+	if err := os.Rename(w.path, backup); err != nil {
+		// Keep writing to the file rather than losing messages.
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.milling.Add(1)
+
+	go w.millBackups(backup, now)
+
+	return nil
+}
+
+// backupName returns the name of a backup rotated at t that does not exist
+// yet.
+func (w *Writer) backupName(t time.Time) string {
+	prefix, ext := w.backupPrefix()
+
+	for {
+		name := prefix + t.UTC().Format(backupTimeFormat) + ext
+
+		_, err := os.Lstat(name)
+		_, gzErr := os.Lstat(name + compressSuffix)
+
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return name
+		}
+
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// backupPrefix returns the parts of the names of backup files before and
+// after their time of rotation.
+func (w *Writer) backupPrefix() (prefix, ext string) {
+	ext = filepath.Ext(w.path)
+
+	return strings.TrimSuffix(w.path, ext) + "-", ext
+}
+
+// millBackups compresses the backup rotated at the given time if needed, and
+// removes the backups in excess of the maximum count or age.
+func (w *Writer) millBackups(backup string, now time.Time) {
+	defer w.milling.Done()
+
+	w.mill.Lock()
+	defer w.mill.Unlock()
+
+	if w.compress {
+		// Errors are ignored, leaving the backup uncompressed.
+		_ = compressFile(backup)
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		return
+	}
+
+	for i, b := range backups {
+		if (w.maxBackups > 0 && i >= w.maxBackups) || (w.maxAge > 0 && now.Sub(b.rotated) > w.maxAge) {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+// backupFile is a backup of the file, rotated at the given time.
+type backupFile struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the backups of the file, the most recent first.
+func (w *Writer) backups() ([]backupFile, error) {
+	prefix, ext := w.backupPrefix()
+
+	infos, err := ioutil.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+
+	for _, info := range infos {
+		path := filepath.Join(filepath.Dir(w.path), info.Name())
+
+		stamp := strings.TrimSuffix(path, compressSuffix)
+		if !strings.HasPrefix(stamp, prefix) || !strings.HasSuffix(stamp, ext) || info.IsDir() {
+			continue
+		}
+
+		stamp = strings.TrimSuffix(strings.TrimPrefix(stamp, prefix), ext)
+
+		rotated, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backupFile{path, rotated})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].rotated.After(backups[j].rotated)
+	})
+
+	return backups, nil
+}
+
+// compressFile replaces the file at path by a gzip compressed copy with the
+// ".gz" suffix.
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644) //nolint:gomnd // rw-r--r--
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(dst.Name())
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+
+	if err = gz.Close(); err != nil {
+		return err
+	}
+
+	if err = dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
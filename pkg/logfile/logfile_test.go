@@ -0,0 +1,348 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package logfile
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "logfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+// fakeClock returns a clock advanced by calls to the returned function.
+func fakeClock() (now func() time.Time, advance func(time.Duration)) {
+	var (
+		mu sync.Mutex
+		t  = time.Date(2021, 3, 4, 10, 6, 7, 0, time.UTC)
+	)
+
+	now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return t
+	}
+
+	advance = func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		t = t.Add(d)
+	}
+
+	return now, advance
+}
+
+func open(t *testing.T, path string, now func() time.Time, opts ...Option) *Writer {
+	t.Helper()
+
+	w, err := Open(path, append(opts, func(w *Writer) { w.now = now })...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func write(t *testing.T, w *Writer, s string) {
+	t.Helper()
+
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// files returns the names and contents of the files of dir.
+func files(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := map[string]string{}
+
+	for _, info := range infos {
+		f, err := os.Open(filepath.Join(dir, info.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var b []byte
+
+		if strings.HasSuffix(info.Name(), compressSuffix) {
+			gz, gzErr := gzip.NewReader(f)
+			if gzErr != nil {
+				t.Fatal(gzErr)
+			}
+
+			b, err = ioutil.ReadAll(gz)
+		} else {
+			b, err = ioutil.ReadAll(f)
+		}
+
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out[info.Name()] = string(b)
+	}
+
+	return out
+}
+
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+
+	got := files(t, dir)
+
+	var names []string
+	for name := range got {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if len(got) != len(want) {
+		t.Fatalf("got files %v, want %d files", names, len(want))
+	}
+
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s: got %q, want %q (files %v)", name, got[name], content, names)
+		}
+	}
+}
+
+func TestWriter_maxSize(t *testing.T) {
+	dir := tempDir(t)
+	now, advance := fakeClock()
+	w := open(t, filepath.Join(dir, "server.log"), now, WithMaxSize(10), WithMaxBackups(2))
+
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffffffffffff\n", "g\n"} {
+		write(t, w, s)
+		advance(time.Second)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFiles(t, dir, map[string]string{
+		"server.log":                         "g\n",
+		"server-2021-03-04T10-06-12.000.log": "eeee\n",
+		"server-2021-03-04T10-06-13.000.log": "ffffffffffff\n",
+	})
+}
+
+func TestWriter_rotateCloseError(t *testing.T) {
+	dir := tempDir(t)
+	now, advance := fakeClock()
+	w := open(t, filepath.Join(dir, "server.log"), now, WithMaxSize(10))
+
+	write(t, w, "aaaa\n")
+	advance(time.Second)
+
+	// Closing the file behind the Writer's back makes the Close of the
+	// rotation fail.
+	w.file.Close()
+
+	if _, err := w.Write([]byte("bbbbbbbb\n")); err == nil {
+		t.Fatal("expected an error when the file cannot be closed")
+	}
+
+	write(t, w, "cccccccc\n")
+	write(t, w, "d\n")
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFiles(t, dir, map[string]string{
+		"server.log":                         "d\n",
+		"server-2021-03-04T10-06-08.000.log": "aaaa\n",
+		"server-2021-03-04T10-06-08.001.log": "cccccccc\n",
+	})
+}
+
+func TestWriter_interval(t *testing.T) {
+	dir := tempDir(t)
+	now, advance := fakeClock()
+	w := open(t, filepath.Join(dir, "server.log"), now, WithInterval(time.Hour), WithCompression())
+
+	advance(2 * time.Hour)
+	write(t, w, "a\n")
+	advance(30 * time.Minute)
+	write(t, w, "b\n")
+	advance(30 * time.Minute)
+	write(t, w, "c\n")
+
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFiles(t, dir, map[string]string{
+		"server.log":                            "",
+		"server-2021-03-04T13-06-07.000.log.gz": "a\nb\n",
+		"server-2021-03-04T13-06-07.001.log.gz": "c\n",
+	})
+}
+
+func TestWriter_maxAge(t *testing.T) {
+	dir := tempDir(t)
+	now, advance := fakeClock()
+	w := open(t, filepath.Join(dir, "app"), now, WithMaxAge(time.Hour))
+
+	for _, s := range []string{"a\n", "b\n", "c\n"} {
+		write(t, w, s)
+
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+
+		advance(45 * time.Minute)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFiles(t, dir, map[string]string{
+		"app":                         "",
+		"app-2021-03-04T10-51-07.000": "b\n",
+		"app-2021-03-04T11-36-07.000": "c\n",
+	})
+}
+
+func TestWriter_Reopen(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, "server.log")
+	now, _ := fakeClock()
+	w := open(t, path, now, WithReopenOnSIGHUP())
+
+	write(t, w, "a\n")
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("cannot send SIGHUP: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("file not reopened")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	write(t, w, "b\n")
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != os.ErrClosed {
+		t.Errorf("second Close: got %v, want %v", err, os.ErrClosed)
+	}
+
+	checkFiles(t, dir, map[string]string{
+		"server.log":   "b\n",
+		"server.log.1": "a\n",
+	})
+}
+
+func TestWriter_concurrent(t *testing.T) {
+	dir := tempDir(t)
+	w, err := Open(filepath.Join(dir, "server.log"), WithMaxSize(100), WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				write(t, w, "0123456789\n")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var total int
+
+	for name, content := range files(t, dir) {
+		if len(content) > 100 {
+			t.Errorf("%s: got %d bytes, want at most 100", name, len(content))
+		}
+
+		total += strings.Count(content, "0123456789\n")
+	}
+
+	if total != 1000 {
+		t.Errorf("got %d lines, want 1000", total)
+	}
+}
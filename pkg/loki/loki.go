@@ -0,0 +1,285 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package loki sends log messages to Grafana Loki, or any server implementing
+// its push API.  Use NewLogger to create a LeveledLogger sending messages to a
+// Writer returned by New:
+//
+//	w, err := loki.New("http://loki:3100/loki/api/v1/push",
+//		loki.WithLabels("component"), loki.WithStaticLabels(map[string]string{"app": "server"}))
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	logger := loki.NewLogger(w, log.WithFilterLevel(level.All))
+package loki
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/logmap"
+)
+
+// LevelLabel is the label holding the level of the messages.
+const LevelLabel = "level"
+
+// Defaults of the Options.
+const (
+	DefaultBatchSize  = 1 << 20
+	DefaultBatchWait  = time.Second
+	DefaultMaxRetries = 10
+	DefaultMinBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff = time.Minute
+	DefaultQueueSize  = 8
+
+	DefaultCloseTimeout = 10 * time.Second
+)
+
+// config holds the settings of the Encoder and Writer.
+type config struct {
+	labels       map[string]bool
+	staticLabels map[string]string
+	line         log.Encoder
+
+	client     *http.Client
+	tenant     string
+	batchSize  int
+	batchWait  time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	queueSize  int
+
+	closeTimeout time.Duration
+}
+
+// Option configures an Encoder, or the Encoder and Writer returned by New.
+type Option func(*config)
+
+// WithLabels returns an Option that uses the fields with the given keys as
+// labels of the streams of the messages, in addition to the LevelLabel.
+// Label names are sanitized to match the label names allowed by Loki.  Keep
+// the number of distinct values of the labels low, as each combination is a
+// separate stream.
+func WithLabels(keys ...string) Option {
+	return func(c *config) {
+		for _, k := range keys {
+			c.labels[k] = true
+		}
+	}
+}
+
+// WithStaticLabels returns an Option that adds the given labels to all the
+// streams, e.g. the name of the application or host.
+func WithStaticLabels(labels map[string]string) Option {
+	return func(c *config) {
+		for k, v := range labels {
+			c.staticLabels[labelName(k)] = v
+		}
+	}
+}
+
+// WithLineEncoder returns an Option that sets the Encoder of the lines of the
+// messages, which hold the fields that are not labels, log.NewLogfmtEncoder()
+// by default.
+func WithLineEncoder(enc log.Encoder) Option {
+	return func(c *config) {
+		c.line = enc
+	}
+}
+
+// WithHTTPClient returns an Option that sets the client used to send the
+// batches, http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithTenant returns an Option that sets the X-Scope-OrgID header of the
+// requests, for multi-tenant servers.
+func WithTenant(id string) Option {
+	return func(c *config) {
+		c.tenant = id
+	}
+}
+
+// WithBatchSize returns an Option that sends a batch as soon as its lines hold
+// the given number of bytes, DefaultBatchSize by default.
+func WithBatchSize(bytes int) Option {
+	return func(c *config) {
+		c.batchSize = bytes
+	}
+}
+
+// WithBatchWait returns an Option that sends a batch at most the given
+// duration after its first message, DefaultBatchWait by default.
+func WithBatchWait(d time.Duration) Option {
+	return func(c *config) {
+		c.batchWait = d
+	}
+}
+
+// WithRetries returns an Option that sets the number of retries of the batches
+// that failed to be sent because of network errors, rate limiting or server
+// errors, and the delays between them, which double after each retry from min
+// up to max.
+func WithRetries(n int, min, max time.Duration) Option {
+	return func(c *config) {
+		c.maxRetries = n
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithQueueSize returns an Option that sets the number of batches waiting to
+// be sent, DefaultQueueSize by default.  When the queue is full, writes wait
+// until a batch is sent.
+func WithQueueSize(n int) Option {
+	return func(c *config) {
+		c.queueSize = n
+	}
+}
+
+// WithCloseTimeout returns an Option that sets the maximum duration Close waits
+// for the queued batches to be sent, DefaultCloseTimeout by default.  The
+// request in progress is then canceled, and the batches that are not sent are
+// dropped, so that Close returns even if the server does not respond.
+func WithCloseTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.closeTimeout = d
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		labels:       map[string]bool{},
+		staticLabels: map[string]string{},
+		line:         log.NewLogfmtEncoder(),
+		client:       http.DefaultClient,
+		batchSize:    DefaultBatchSize,
+		batchWait:    DefaultBatchWait,
+		maxRetries:   DefaultMaxRetries,
+		minBackoff:   DefaultMinBackoff,
+		maxBackoff:   DefaultMaxBackoff,
+		queueSize:    DefaultQueueSize,
+		closeTimeout: DefaultCloseTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// Encoder is a log.Encoder splitting the fields of the messages into the
+// labels of their stream and their line, which is encoded by the line Encoder.
+// Its output is a JSON object meant to be decoded by a Writer, e.g.
+//
+//	{"ts":"1614852367890123456","labels":{"level":"info"},"line":"level=info msg=hello"}
+type Encoder struct {
+	config
+}
+
+// NewEncoder returns a new Encoder.  Its defaults can be changed by providing
+// Options.
+func NewEncoder(opts ...Option) *Encoder {
+	return &Encoder{newConfig(opts)}
+}
+
+// entry is the output of the Encoder.
+type entry struct {
+	Time   string            `json:"ts"`
+	Labels map[string]string `json:"labels"`
+	Line   string            `json:"line"`
+}
+
+// Encode implements the log.Encoder interface.
+func (enc *Encoder) Encode(e *log.Entry) ([]byte, error) {
+	labels := make(map[string]string, len(enc.staticLabels)+1)
+
+	for k, v := range enc.staticLabels {
+		labels[k] = v
+	}
+
+	var keyvals []interface{}
+
+	for i := 0; i < len(e.Keyvals); i += 2 {
+		if i+1 < len(e.Keyvals) {
+			if k, ok := e.Keyvals[i].(string); ok && enc.labels[k] {
+				v, err := logmap.TextFromValue(e.Keyvals[i+1])
+				if err != nil {
+					return nil, err
+				}
+
+				labels[labelName(k)] = v
+
+				continue
+			}
+		}
+
+		end := i + 2
+		if end > len(e.Keyvals) {
+			end = len(e.Keyvals)
+		}
+
+		keyvals = append(keyvals, e.Keyvals[i:end]...)
+	}
+
+	labels[LevelLabel] = e.Level.String()
+
+	line, err := enc.line.Encode(&log.Entry{Time: e.Time, Level: e.Level, Keyvals: keyvals, Keys: e.Keys})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(entry{
+		Time:   strconv.FormatInt(e.Time.UnixNano(), 10), //nolint:gomnd // decimal
+		Labels: labels,
+		Line:   strings.TrimSuffix(string(line), "\n"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// labelName replaces the characters not allowed in label names with
+// underscores.
+func labelName(key string) string {
+	b := []byte(key)
+
+	for i, c := range b {
+		letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+
+	if len(b) == 0 {
+		return "_"
+	}
+
+	return string(b)
+}
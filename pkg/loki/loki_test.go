@@ -0,0 +1,91 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package loki
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+type failingTextMarshaler struct{}
+
+func (failingTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("marshal failure")
+}
+
+func TestEncoder(t *testing.T) {
+	tm := time.Date(2021, 3, 4, 10, 6, 7, 890123456, time.UTC)
+
+	tests := map[string]struct {
+		opts  []Option
+		entry log.Entry
+		want  string
+	}{
+		"default": {
+			nil,
+			log.Entry{Time: tm, Level: level.Info, Keyvals: []interface{}{"msg", "hello", "component", "asr"}},
+			`{"ts":"1614852367890123456","labels":{"level":"info"},"line":"level=info msg=hello component=asr"}`,
+		},
+		"labels": {
+			[]Option{WithLabels("component", "odd"), WithStaticLabels(map[string]string{"app-name": "server"})},
+			log.Entry{Time: tm, Level: level.Error, Keyvals: []interface{}{"msg", "failed", "component", "asr", "odd"}},
+			`{"ts":"1614852367890123456","labels":{"app_name":"server","component":"asr","level":"error"},"line":"level=error msg=failed odd=missing"}`,
+		},
+		"json_line": {
+			[]Option{WithLabels("component"), WithLineEncoder(log.NewJSONEncoder(log.WithLevelKey("level")))},
+			log.Entry{Time: tm, Level: level.Debug, Keyvals: []interface{}{"component", "asr", "msg", "hi"}},
+			`{"ts":"1614852367890123456","labels":{"component":"asr","level":"debug"},"line":"{\"level\":\"debug\",\"msg\":\"hi\"}"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := NewEncoder(tc.opts...).Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if strings.TrimSuffix(string(got), "\n") != tc.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", name, got, tc.want)
+		}
+	}
+
+	_, err := NewEncoder(WithLabels("key")).Encode(&log.Entry{Keyvals: []interface{}{"key", failingTextMarshaler{}}})
+	if err == nil {
+		t.Error("expected an error for a failing TextMarshaler")
+	}
+}
+
+func TestLabelName(t *testing.T) {
+	tests := map[string]string{
+		"":          "_",
+		"component": "component",
+		"http.port": "http_port",
+		"2fa":       "_fa",
+		"a2":        "a2",
+	}
+
+	for key, want := range tests {
+		if got := labelName(key); got != want {
+			t.Errorf("labelName(%q): got %q, want %q", key, got, want)
+		}
+	}
+}
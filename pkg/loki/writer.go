@@ -0,0 +1,237 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package loki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/httpbatch"
+)
+
+// ErrClosed is returned by Write after Close.
+var ErrClosed = errors.New("loki: writer closed")
+
+// Writer is an io.Writer sending the messages formatted by its Encoder to a
+// Loki push endpoint.  Messages are grouped into batches, which are sent when
+// they reach the batch size or the batch wait duration.  Batches are sent by a
+// separate goroutine; writes wait when the queue of batches is full, so that
+// messages are not lost while the server is slow.  Batches failing with
+// network errors, 429 or 5xx responses are retried, and other batches are
+// dropped.
+//
+// A Writer is safe for concurrent use.
+type Writer struct {
+	url     string
+	encoder *Encoder
+	config
+
+	batches *httpbatch.Writer
+}
+
+// New returns a new Writer sending batches to the push endpoint at the given
+// URL, e.g. "http://loki:3100/loki/api/v1/push".  The Options configure the
+// Writer and its Encoder.
+func New(url string, opts ...Option) (*Writer, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("loki: invalid push URL %q", url)
+	}
+
+	enc := NewEncoder(opts...)
+	w := &Writer{url: url, encoder: enc, config: enc.config}
+
+	w.batches = httpbatch.New(httpbatch.Config{
+		BatchWait:    w.batchWait,
+		MaxRetries:   w.maxRetries,
+		MinBackoff:   w.minBackoff,
+		MaxBackoff:   w.maxBackoff,
+		QueueSize:    w.queueSize,
+		CloseTimeout: w.closeTimeout,
+		ErrClosed:    ErrClosed,
+	}, w.newBatch, w.post)
+
+	return w, nil
+}
+
+// NewLogger returns a new LeveledLogger sending its messages to w, formatted
+// by the Encoder of w.  The Options are applied before those setting the
+// output, the Encoder and the timestamps.
+func NewLogger(w *Writer, opts ...log.Option) *log.LeveledLogger {
+	opts = append(opts, log.WithOutput(w), log.WithEncoder(w.Encoder()), log.WithTimestamp(log.TimestampNone))
+
+	return log.NewLeveledLogger(opts...)
+}
+
+// Encoder returns the Encoder formatting the messages sent by w.
+func (w *Writer) Encoder() *Encoder {
+	return w.encoder
+}
+
+// Write adds the message formatted by the Encoder to the current batch.
+func (w *Writer) Write(p []byte) (int, error) {
+	var e entry
+	if err := json.Unmarshal(p, &e); err != nil {
+		return 0, err
+	}
+
+	if err := w.batches.Add(&e); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of messages dropped because their batch could
+// not be sent.
+func (w *Writer) Dropped() uint64 {
+	return w.batches.Dropped()
+}
+
+// Close sends the current batch and waits until the queued batches are sent or
+// dropped, for up to the close timeout set with WithCloseTimeout.
+func (w *Writer) Close() error {
+	return w.batches.Close()
+}
+
+// newBatch returns a new empty batch.
+func (w *Writer) newBatch() httpbatch.Batch {
+	return &batch{streams: map[string]*stream{}, limit: w.batchSize}
+}
+
+// post sends the body of a batch, and reports whether it may be retried if it
+// failed.
+func (w *Writer) post(ctx context.Context, body []byte) (bool, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Encoding", "gzip")
+
+	if w.tenant != "" {
+		header.Set("X-Scope-OrgID", w.tenant)
+	}
+
+	code, err := httpbatch.Post(ctx, w.client, w.url, header, body)
+	if err != nil {
+		return true, err
+	}
+
+	if code/100 == 2 { //nolint:gomnd // 2xx
+		return false, nil
+	}
+
+	return code == http.StatusTooManyRequests || code/100 == 5, httpbatch.StatusError("loki: push failed", code) //nolint:gomnd // 5xx
+}
+
+// batch holds messages grouped by stream, up to limit bytes.
+type batch struct {
+	streams map[string]*stream
+	size    int
+	count   int
+	limit   int
+}
+
+type stream struct {
+	Labels map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Add adds the *entry to the stream of its labels.
+func (b *batch) Add(msg interface{}) {
+	e := msg.(*entry)
+	key := streamKey(e.Labels)
+
+	s, ok := b.streams[key]
+	if !ok {
+		s = &stream{Labels: e.Labels}
+		b.streams[key] = s
+	}
+
+	s.Values = append(s.Values, [2]string{e.Time, e.Line})
+	b.size += len(e.Line)
+	b.count++
+}
+
+// Count returns the number of messages of the batch.
+func (b *batch) Count() int {
+	return b.count
+}
+
+// Full reports whether the size of the lines reached the limit.
+func (b *batch) Full() bool {
+	return b.size >= b.limit
+}
+
+// Encode returns the gzip compressed body of the push request.  The values of
+// each stream are sorted by time, as required by Loki.
+func (b *batch) Encode() ([]byte, error) {
+	var req struct {
+		Streams []*stream `json:"streams"`
+	}
+
+	keys := make([]string, 0, len(b.streams))
+	for k := range b.streams {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := b.streams[k]
+
+		sort.SliceStable(s.Values, func(i, j int) bool {
+			return nanos(s.Values[i][0]) < nanos(s.Values[j][0])
+		})
+
+		req.Streams = append(req.Streams, s)
+	}
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+
+	if err := json.NewEncoder(gz).Encode(&req); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// streamKey returns a string identifying the labels.
+func streamKey(labels map[string]string) string {
+	b, _ := json.Marshal(labels) // maps are marshaled with sorted keys
+
+	return string(b)
+}
+
+// nanos parses a timestamp of the push API.
+func nanos(ts string) int64 {
+	n, _ := strconv.ParseInt(ts, 10, 64) //nolint:gomnd // decimal int64
+
+	return n
+}
@@ -0,0 +1,216 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package loki
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+type pushRequest struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+// server records the push requests it receives, and responds with the given
+// status codes before responding with 204.
+type server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []pushRequest
+	tenants  []string
+	statuses []int
+}
+
+func newServer(t *testing.T, statuses ...int) *server {
+	t.Helper()
+
+	s := &server{statuses: statuses}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if len(s.statuses) > 0 {
+			rw.WriteHeader(s.statuses[0])
+			s.statuses = s.statuses[1:]
+
+			return
+		}
+
+		if r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+
+			return
+		}
+
+		var req pushRequest
+		if err := json.NewDecoder(gz).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		s.requests = append(s.requests, req)
+		s.tenants = append(s.tenants, r.Header.Get("X-Scope-OrgID"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestWriter(t *testing.T) {
+	s := newServer(t)
+
+	w, err := New(s.URL, WithLabels("component"), WithTenant("team"), WithBatchWait(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogger(w, log.WithFilterLevel(level.All))
+	l.Info("msg", "first", "component", "asr")
+	l.Error("msg", "second", "component", "tts")
+	l.Info("msg", "third", "component", "asr")
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(s.requests))
+	}
+
+	req := s.requests[0]
+	if len(req.Streams) != 2 || s.tenants[0] != "team" {
+		t.Fatalf("unexpected request %+v for tenant %q", req, s.tenants[0])
+	}
+
+	asr := req.Streams[0]
+	if asr.Stream["component"] != "asr" || asr.Stream["level"] != "info" || len(asr.Values) != 2 {
+		t.Errorf("unexpected stream %+v", asr)
+	}
+
+	if asr.Values[0][1] != "level=info msg=first" || asr.Values[0][0] > asr.Values[1][0] {
+		t.Errorf("unexpected values %v", asr.Values)
+	}
+
+	if w.Dropped() != 0 {
+		t.Errorf("got %d dropped, want 0", w.Dropped())
+	}
+}
+
+func TestWriter_batching(t *testing.T) {
+	s := newServer(t)
+
+	w, err := New(s.URL, WithBatchSize(40), WithBatchWait(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogger(w)
+
+	// The first two lines fill a batch.
+	l.Info("msg", "0123456789")
+	l.Info("msg", "0123456789")
+
+	// The third line is sent after the batch wait.
+	l.Info("msg", "third")
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		s.mu.Lock()
+		n := len(s.requests)
+		s.mu.Unlock()
+
+		if n == 2 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d requests, want 2", n)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != ErrClosed {
+		t.Errorf("second Close: got %v, want %v", err, ErrClosed)
+	}
+
+	if _, err := w.Write([]byte("{}")); err != ErrClosed {
+		t.Errorf("Write after Close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestWriter_retries(t *testing.T) {
+	tests := map[string]struct {
+		statuses []int
+		requests int
+		dropped  uint64
+	}{
+		"retried":     {[]int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 1, 0},
+		"bad_request": {[]int{http.StatusBadRequest}, 0, 1},
+		"too_many":    {[]int{500, 500, 500, 500}, 0, 1},
+	}
+
+	for name, tc := range tests {
+		s := newServer(t, tc.statuses...)
+
+		w, err := New(s.URL, WithRetries(2, time.Millisecond, 2*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		NewLogger(w).Info("msg", "hello")
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(s.requests) != tc.requests || w.Dropped() != tc.dropped {
+			t.Errorf("%s: got %d requests and %d dropped, want %d and %d",
+				name, len(s.requests), w.Dropped(), tc.requests, tc.dropped)
+		}
+	}
+}
+
+func TestNew_invalidURL(t *testing.T) {
+	if _, err := New("loki:3100"); err == nil {
+		t.Error("expected an error for a URL without scheme")
+	}
+}
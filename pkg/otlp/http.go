@@ -0,0 +1,279 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cobaltspeech/log/internal/httpbatch"
+)
+
+// Defaults of the Options of the HTTPWriter.
+const (
+	DefaultBatchSize  = 512
+	DefaultBatchWait  = time.Second
+	DefaultMaxRetries = 5
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+	DefaultQueueSize  = 8
+
+	DefaultCloseTimeout = 10 * time.Second
+)
+
+// ErrClosed is returned by the Write method of an HTTPWriter after Close.
+var ErrClosed = errors.New("otlp: writer closed")
+
+// httpConfig holds the settings of the HTTPWriter.
+type httpConfig struct {
+	client     *http.Client
+	headers    map[string]string
+	batchSize  int
+	batchWait  time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	queueSize  int
+
+	closeTimeout time.Duration
+}
+
+func defaultHTTPConfig() httpConfig {
+	return httpConfig{
+		client:     http.DefaultClient,
+		headers:    map[string]string{},
+		batchSize:  DefaultBatchSize,
+		batchWait:  DefaultBatchWait,
+		maxRetries: DefaultMaxRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		queueSize:  DefaultQueueSize,
+
+		closeTimeout: DefaultCloseTimeout,
+	}
+}
+
+// WithHTTPClient returns an Option that sets the client used by the HTTPWriter,
+// http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithHeaders returns an Option that adds headers to the requests of the
+// HTTPWriter, e.g. for authentication.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		for k, v := range headers {
+			c.headers[k] = v
+		}
+	}
+}
+
+// WithBatchSize returns an Option that sets the maximum number of log records
+// sent by the HTTPWriter in a request, DefaultBatchSize by default.
+func WithBatchSize(n int) Option {
+	return func(c *config) {
+		c.batchSize = n
+	}
+}
+
+// WithBatchWait returns an Option that sets the maximum duration the
+// HTTPWriter waits for more log records before sending a request,
+// DefaultBatchWait by default.
+func WithBatchWait(d time.Duration) Option {
+	return func(c *config) {
+		c.batchWait = d
+	}
+}
+
+// WithRetries returns an Option that sets the number of retries of the
+// requests of the HTTPWriter that failed because of network errors or
+// retryable status codes, and the delays between them, which double after each
+// retry from min up to max.
+func WithRetries(n int, min, max time.Duration) Option {
+	return func(c *config) {
+		c.maxRetries = n
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithQueueSize returns an Option that sets the number of requests waiting to
+// be sent by the HTTPWriter, DefaultQueueSize by default.  When the queue is
+// full, writes wait until a request is sent.
+func WithQueueSize(n int) Option {
+	return func(c *config) {
+		c.queueSize = n
+	}
+}
+
+// WithCloseTimeout returns an Option that sets the maximum duration Close waits
+// for the queued requests to be sent, DefaultCloseTimeout by default.  The
+// request in progress is then canceled, and the requests that are not sent are
+// dropped, so that Close returns even if the server does not respond.
+func WithCloseTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.closeTimeout = d
+	}
+}
+
+// HTTPWriter is an io.Writer sending the export requests written by its
+// Encoder to an OTLP/HTTP endpoint, in batches of log records.  Requests are
+// sent by a separate goroutine; writes wait when the queue of requests is
+// full.  Requests failing with network errors or the status codes 429, 502,
+// 503 and 504 are retried, and other failed requests are dropped.
+//
+// An HTTPWriter is safe for concurrent use.
+type HTTPWriter struct {
+	url     string
+	encoder *Encoder
+
+	batches *httpbatch.Writer
+}
+
+// NewHTTPWriter returns a new HTTPWriter sending requests to the given URL,
+// e.g. "http://collector:4318/v1/logs".  The Options configure the HTTPWriter
+// and its Encoder.
+func NewHTTPWriter(url string, opts ...Option) (*HTTPWriter, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("otlp: invalid endpoint URL %q", url)
+	}
+
+	enc := NewEncoder(opts...)
+	w := &HTTPWriter{url: url, encoder: enc}
+
+	w.batches = httpbatch.New(httpbatch.Config{
+		BatchWait:    enc.batchWait,
+		MaxRetries:   enc.maxRetries,
+		MinBackoff:   enc.minBackoff,
+		MaxBackoff:   enc.maxBackoff,
+		QueueSize:    enc.queueSize,
+		CloseTimeout: enc.closeTimeout,
+		ErrClosed:    ErrClosed,
+	}, w.newBatch, w.post)
+
+	return w, nil
+}
+
+// Encoder returns the Encoder formatting the log records sent by w.
+func (w *HTTPWriter) Encoder() *Encoder {
+	return w.encoder
+}
+
+// Write adds the log records of the export request written by the Encoder to
+// the current batch.
+func (w *HTTPWriter) Write(p []byte) (int, error) {
+	var req exportRequest
+	if err := json.Unmarshal(p, &req); err != nil {
+		return 0, err
+	}
+
+	var records []interface{}
+
+	for _, rl := range req.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			for _, rec := range sl.LogRecords {
+				records = append(records, rec)
+			}
+		}
+	}
+
+	if err := w.batches.Add(records...); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Dropped returns the number of log records dropped because their request
+// failed.
+func (w *HTTPWriter) Dropped() uint64 {
+	return w.batches.Dropped()
+}
+
+// Close sends the current batch and waits until the queued requests are sent
+// or dropped, for up to the close timeout set with WithCloseTimeout.
+func (w *HTTPWriter) Close() error {
+	return w.batches.Close()
+}
+
+// newBatch returns a new empty batch.
+func (w *HTTPWriter) newBatch() httpbatch.Batch {
+	return &batch{resource: w.encoder.resource, limit: w.encoder.batchSize}
+}
+
+// post sends the body of a request, and reports whether it may be retried if
+// it failed.
+func (w *HTTPWriter) post(ctx context.Context, body []byte) (bool, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	for k, v := range w.encoder.headers {
+		header.Set(k, v)
+	}
+
+	code, err := httpbatch.Post(ctx, w.encoder.client, w.url, header, body)
+	if err != nil {
+		return true, err
+	}
+
+	switch code {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent:
+		return false, nil
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, httpbatch.StatusError("otlp: export failed", code)
+	default:
+		return false, httpbatch.StatusError("otlp: export failed", code)
+	}
+}
+
+// batch holds up to limit log records sent in a request.
+type batch struct {
+	resource []keyValue
+	records  []json.RawMessage
+	limit    int
+}
+
+// Add adds the json.RawMessage log record to the batch.
+func (b *batch) Add(msg interface{}) {
+	b.records = append(b.records, msg.(json.RawMessage))
+}
+
+// Count returns the number of log records of the batch.
+func (b *batch) Count() int {
+	return len(b.records)
+}
+
+// Full reports whether the batch holds limit log records.
+func (b *batch) Full() bool {
+	return len(b.records) >= b.limit
+}
+
+// Encode returns the export request of the log records.
+func (b *batch) Encode() ([]byte, error) {
+	return json.Marshal(exportRequest{[]resourceLogs{{
+		Resource:  resource{b.resource},
+		ScopeLogs: []scopeLogs{{Scope: scope{ScopeName}, LogRecords: b.records}},
+	}}})
+}
@@ -0,0 +1,174 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package otlp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server records the log records it receives, and responds with the given
+// status codes before responding with 200.
+type server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []exportRequest
+	headers  []http.Header
+	statuses []int
+}
+
+func newServer(t *testing.T, statuses ...int) *server {
+	t.Helper()
+
+	s := &server{statuses: statuses}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if len(s.statuses) > 0 {
+			rw.WriteHeader(s.statuses[0])
+			s.statuses = s.statuses[1:]
+
+			return
+		}
+
+		var req exportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		s.requests = append(s.requests, req)
+		s.headers = append(s.headers, r.Header)
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func TestHTTPWriter(t *testing.T) {
+	s := newServer(t)
+
+	w, err := NewHTTPWriter(s.URL, WithBatchSize(2), WithBatchWait(time.Hour),
+		WithHeaders(map[string]string{"Authorization": "Bearer token"}), WithResource("service.name", "server"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewLogger(w, w.Encoder())
+	l.Info("msg", "first")
+	l.Info("msg", "second")
+	l.Error("msg", "third")
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(s.requests))
+	}
+
+	for i, want := range []int{2, 1} {
+		rl := s.requests[i].ResourceLogs
+		if len(rl) != 1 || len(rl[0].Resource.Attributes) != 1 || len(rl[0].ScopeLogs[0].LogRecords) != want {
+			t.Errorf("request %d: unexpected request %+v", i, s.requests[i])
+		}
+
+		if s.headers[i].Get("Authorization") != "Bearer token" || s.headers[i].Get("Content-Type") != "application/json" {
+			t.Errorf("request %d: unexpected headers %v", i, s.headers[i])
+		}
+	}
+
+	if err := w.Close(); err != ErrClosed {
+		t.Errorf("second Close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestHTTPWriter_batchWait(t *testing.T) {
+	s := newServer(t)
+
+	w, err := NewHTTPWriter(s.URL, WithBatchWait(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	NewLogger(w, w.Encoder()).Info("msg", "hello")
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		s.mu.Lock()
+		n := len(s.requests)
+		s.mu.Unlock()
+
+		if n == 1 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("batch not sent")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHTTPWriter_retries(t *testing.T) {
+	tests := map[string]struct {
+		statuses []int
+		requests int
+		dropped  uint64
+	}{
+		"retried":     {[]int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 1, 0},
+		"bad_request": {[]int{http.StatusBadRequest}, 0, 1},
+		"too_many":    {[]int{502, 502, 502}, 0, 1},
+	}
+
+	for name, tc := range tests {
+		s := newServer(t, tc.statuses...)
+
+		w, err := NewHTTPWriter(s.URL, WithRetries(2, time.Millisecond, 2*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		NewLogger(w, w.Encoder()).Info("msg", "hello")
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(s.requests) != tc.requests || w.Dropped() != tc.dropped {
+			t.Errorf("%s: got %d requests and %d dropped, want %d and %d",
+				name, len(s.requests), w.Dropped(), tc.requests, tc.dropped)
+		}
+	}
+}
+
+func TestNewHTTPWriter_invalidURL(t *testing.T) {
+	if _, err := NewHTTPWriter("collector:4318"); err == nil {
+		t.Error("expected an error for a URL without scheme")
+	}
+}
@@ -0,0 +1,429 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package otlp exports log messages in the OpenTelemetry log data model,
+// encoded as OTLP/JSON.  Messages may be written to a file, one export request
+// per line as expected by the file receiver of the OpenTelemetry Collector:
+//
+//	logger := otlp.NewLogger(f, otlp.NewEncoder(otlp.WithResource("service.name", "server")))
+//
+// or sent to an OTLP/HTTP endpoint by an HTTPWriter:
+//
+//	w, err := otlp.NewHTTPWriter("http://collector:4318/v1/logs", otlp.WithResource("service.name", "server"))
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	logger := otlp.NewLogger(w, w.Encoder())
+package otlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// Default keys of the fields holding the trace and span IDs of the messages.
+// See WithTraceKeys.
+const (
+	DefaultTraceIDKey = "trace_id"
+	DefaultSpanIDKey  = "span_id"
+)
+
+// ScopeName is the name of the instrumentation scope of the log records.
+const ScopeName = "github.com/cobaltspeech/log"
+
+// Severity numbers of the OpenTelemetry log data model used for the levels.
+const (
+	severityTrace = 1
+	severityDebug = 5
+	severityInfo  = 9
+	severityError = 17
+)
+
+// Lengths of the hexadecimal trace and span IDs.
+const (
+	traceIDLength = 32
+	spanIDLength  = 16
+)
+
+// config holds the settings of the Encoder and HTTPWriter.
+type config struct {
+	resource   []keyValue
+	messageKey string
+	traceKey   string
+	spanKey    string
+
+	httpConfig
+}
+
+// Option configures an Encoder, or an HTTPWriter and its Encoder.
+type Option func(*config)
+
+// WithResource returns an Option that sets the attributes of the resource
+// producing the messages, given as alternating keys and values, e.g.
+// "service.name", "server", "service.version", "1.2.0".
+func WithResource(keyvals ...interface{}) Option {
+	return func(c *config) {
+		c.resource = append(c.resource, attributes(logmap.FromKeyvals(keyvals...))...)
+	}
+}
+
+// WithMessageKey returns an Option that sets the key of the field used as the
+// body of the log records, log.MessageKey by default.  It must match the name
+// given to log.WithKeyNames, if any.
+func WithMessageKey(key string) Option {
+	return func(c *config) {
+		c.messageKey = key
+	}
+}
+
+// WithTraceKeys returns an Option that sets the keys of the fields holding the
+// trace and span IDs of the messages, DefaultTraceIDKey and DefaultSpanIDKey by
+// default.  Fields holding valid hexadecimal IDs are written as the trace and
+// span IDs of the log records rather than as attributes.
+func WithTraceKeys(traceKey, spanKey string) Option {
+	return func(c *config) {
+		c.traceKey = traceKey
+		c.spanKey = spanKey
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		messageKey: log.MessageKey,
+		traceKey:   DefaultTraceIDKey,
+		spanKey:    DefaultSpanIDKey,
+		httpConfig: defaultHTTPConfig(),
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// Encoder is a log.Encoder converting the messages to log records of the
+// OpenTelemetry log data model.  The level is written as the severity, the
+// field with the log.MessageKey key as the body, the trace and span ID fields
+// as the trace context, and the other fields as attributes.  Booleans,
+// integers and floating point numbers, including those of named types, are
+// written with their native types.  Slices and arrays are written as array
+// values, and maps and structs as key-value lists, with the keys and values of
+// their JSON encoding.  Values implementing json.Marshaler or
+// encoding.TextMarshaler, and other values, are written as strings.
+//
+// Each message is written as an OTLP/JSON export request on a single line.
+type Encoder struct {
+	config
+}
+
+// NewEncoder returns a new Encoder.  Its defaults can be changed by providing
+// Options.
+func NewEncoder(opts ...Option) *Encoder {
+	return &Encoder{newConfig(opts)}
+}
+
+// NewLogger returns a new LeveledLogger writing its messages to w, formatted
+// by enc.  The Options are applied before those setting the output, the
+// Encoder and the timestamps.
+func NewLogger(w io.Writer, enc *Encoder, opts ...log.Option) *log.LeveledLogger {
+	opts = append(opts, log.WithOutput(w), log.WithEncoder(enc), log.WithTimestamp(log.TimestampNone))
+
+	return log.NewLeveledLogger(opts...)
+}
+
+// exportRequest is an OTLP/JSON export logs service request.
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope             `json:"scope"`
+	LogRecords []json.RawMessage `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 *anyValue  `json:"body,omitempty"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string      `json:"stringValue,omitempty"`
+	BoolValue   *bool        `json:"boolValue,omitempty"`
+	IntValue    *string      `json:"intValue,omitempty"`
+	DoubleValue *float64     `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *kvlistValue `json:"kvlistValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+type kvlistValue struct {
+	Values []keyValue `json:"values"`
+}
+
+// Encode implements the log.Encoder interface.
+func (enc *Encoder) Encode(e *log.Entry) ([]byte, error) {
+	ts := strconv.FormatInt(e.Time.UnixNano(), 10) //nolint:gomnd // decimal
+	number, text := severity(e.Level)
+
+	rec := logRecord{
+		TimeUnixNano:         ts,
+		ObservedTimeUnixNano: ts,
+		SeverityNumber:       number,
+		SeverityText:         text,
+	}
+
+	var fields logmap.MapSlice
+
+	for _, item := range logmap.FromKeyvalsTyped(e.Keyvals...) {
+		switch {
+		case item.Key == enc.messageKey && rec.Body == nil:
+			v, err := value(item.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			rec.Body = &v
+		case item.Key == enc.traceKey && rec.TraceID == "" && isHexID(item.Value, traceIDLength):
+			rec.TraceID = item.Value.(string)
+		case item.Key == enc.spanKey && rec.SpanID == "" && isHexID(item.Value, spanIDLength):
+			rec.SpanID = item.Value.(string)
+		default:
+			fields = append(fields, item)
+		}
+	}
+
+	for _, item := range fields {
+		v, err := value(item.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		rec.Attributes = append(rec.Attributes, keyValue{item.Key, v})
+	}
+
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = json.Marshal(exportRequest{[]resourceLogs{{
+		Resource:  resource{enc.resource},
+		ScopeLogs: []scopeLogs{{Scope: scope{ScopeName}, LogRecords: []json.RawMessage{b}}},
+	}}})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// severity returns the severity number and text of the level.
+func severity(lvl level.Level) (int, string) {
+	switch lvl {
+	case level.Error:
+		return severityError, "ERROR"
+	case level.Info:
+		return severityInfo, "INFO"
+	case level.Debug:
+		return severityDebug, "DEBUG"
+	default:
+		return severityTrace, "TRACE"
+	}
+}
+
+// attributes converts the fields to attributes, formatting all the values to
+// strings.
+func attributes(ms logmap.MapSlice) []keyValue {
+	kvs := make([]keyValue, 0, len(ms))
+
+	for _, item := range ms {
+		s := logmap.StringFromValue(item.Value)
+		kvs = append(kvs, keyValue{item.Key, anyValue{StringValue: &s}})
+	}
+
+	return kvs
+}
+
+// value converts a value returned by logmap.FromKeyvalsTyped to an attribute
+// value.
+func value(v interface{}) (anyValue, error) {
+	switch val := v.(type) {
+	case bool:
+		return anyValue{BoolValue: &val}, nil
+	case int, int8, int16, int32, int64:
+		s := strconv.FormatInt(reflect.ValueOf(val).Int(), 10) //nolint:gomnd // decimal
+
+		return anyValue{IntValue: &s}, nil
+	case uint, uint8, uint16, uint32, uint64, uintptr:
+		u := reflect.ValueOf(val).Uint()
+		s := strconv.FormatUint(u, 10) //nolint:gomnd // decimal
+
+		if u > math.MaxInt64 {
+			return anyValue{StringValue: &s}, nil
+		}
+
+		return anyValue{IntValue: &s}, nil
+	case float32, float64:
+		f := reflect.ValueOf(val).Float()
+
+		return anyValue{DoubleValue: &f}, nil
+	case string:
+		return anyValue{StringValue: &val}, nil
+	case json.RawMessage:
+		dec := json.NewDecoder(bytes.NewReader(val))
+		dec.UseNumber()
+
+		return jsonValue(dec)
+	default:
+		s, err := logmap.TextFromValue(v)
+		if err != nil {
+			return anyValue{}, err
+		}
+
+		return anyValue{StringValue: &s}, nil
+	}
+}
+
+// jsonValue converts the next JSON value of the decoder to an attribute value,
+// keeping the order of the keys of objects.  The decoder must use numbers.
+func jsonValue(dec *json.Decoder) (anyValue, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return anyValue{}, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '[' {
+			arr := &arrayValue{Values: []anyValue{}}
+
+			for dec.More() {
+				v, err := jsonValue(dec)
+				if err != nil {
+					return anyValue{}, err
+				}
+
+				arr.Values = append(arr.Values, v)
+			}
+
+			_, err = dec.Token() // ]
+
+			return anyValue{ArrayValue: arr}, err
+		}
+
+		kvs := &kvlistValue{Values: []keyValue{}}
+
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return anyValue{}, err
+			}
+
+			v, err := jsonValue(dec)
+			if err != nil {
+				return anyValue{}, err
+			}
+
+			kvs.Values = append(kvs.Values, keyValue{key.(string), v})
+		}
+
+		_, err = dec.Token() // }
+
+		return anyValue{KvlistValue: kvs}, err
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			s := t.String()
+
+			return anyValue{IntValue: &s}, nil
+		}
+
+		if _, err := strconv.ParseUint(t.String(), 10, 64); err == nil { //nolint:gomnd // decimal uint64
+			s := t.String()
+
+			return anyValue{StringValue: &s}, nil
+		}
+
+		f, err := t.Float64()
+
+		return anyValue{DoubleValue: &f}, err
+	case string:
+		return anyValue{StringValue: &t}, nil
+	case bool:
+		return anyValue{BoolValue: &t}, nil
+	default: // null
+		return anyValue{}, nil
+	}
+}
+
+// isHexID reports whether v is a non-zero hexadecimal ID of the given length.
+func isHexID(v interface{}, length int) bool {
+	s, ok := v.(string)
+	if !ok || len(s) != length {
+		return false
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return false
+	}
+
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+
+	return false
+}
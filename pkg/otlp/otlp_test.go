@@ -0,0 +1,132 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package otlp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+type namedInt int
+
+type failingTextMarshaler struct{}
+
+func (failingTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("marshal failure")
+}
+
+func TestEncoder(t *testing.T) {
+	tm := time.Date(2021, 3, 4, 10, 6, 7, 890123456, time.UTC)
+
+	tests := map[string]struct {
+		opts  []Option
+		entry log.Entry
+		want  string
+	}{
+		"attributes": {
+			[]Option{WithResource("service.name", "server", "replicas", 3)},
+			log.Entry{Time: tm, Level: level.Info, Keyvals: []interface{}{
+				"msg", "started", "port", 8080, "tls", true, "load", 0.5, "big", uint64(1 << 63), "tags", []string{"a"},
+			}},
+			`{"resourceLogs":[{"resource":{"attributes":[` +
+				`{"key":"service.name","value":{"stringValue":"server"}},{"key":"replicas","value":{"stringValue":"3"}}]},` +
+				`"scopeLogs":[{"scope":{"name":"github.com/cobaltspeech/log"},"logRecords":[{` +
+				`"timeUnixNano":"1614852367890123456","observedTimeUnixNano":"1614852367890123456",` +
+				`"severityNumber":9,"severityText":"INFO","body":{"stringValue":"started"},"attributes":[` +
+				`{"key":"port","value":{"intValue":"8080"}},{"key":"tls","value":{"boolValue":true}},` +
+				`{"key":"load","value":{"doubleValue":0.5}},{"key":"big","value":{"stringValue":"9223372036854775808"}},` +
+				`{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"}]}}}]}]}]}]}`,
+		},
+		"composite": {
+			nil,
+			log.Entry{Time: tm, Level: level.Info, Keyvals: []interface{}{
+				"port", namedInt(443), "peer", struct {
+					Host  string
+					Ports []int
+					Load  float64
+					Up    bool
+					Tags  map[string]string
+					Owner *string
+				}{"db", []int{1, 2}, 1.5, true, map[string]string{"b": "x", "a": "y"}, nil},
+			}},
+			`{"resourceLogs":[{"resource":{},"scopeLogs":[{"scope":{"name":"github.com/cobaltspeech/log"},"logRecords":[{` +
+				`"timeUnixNano":"1614852367890123456","observedTimeUnixNano":"1614852367890123456",` +
+				`"severityNumber":9,"severityText":"INFO","attributes":[{"key":"port","value":{"intValue":"443"}},` +
+				`{"key":"peer","value":{"kvlistValue":{"values":[{"key":"Host","value":{"stringValue":"db"}},` +
+				`{"key":"Ports","value":{"arrayValue":{"values":[{"intValue":"1"},{"intValue":"2"}]}}},` +
+				`{"key":"Load","value":{"doubleValue":1.5}},{"key":"Up","value":{"boolValue":true}},` +
+				`{"key":"Tags","value":{"kvlistValue":{"values":[{"key":"a","value":{"stringValue":"y"}},` +
+				`{"key":"b","value":{"stringValue":"x"}}]}}},{"key":"Owner","value":{}}]}}}]}]}]}]}`,
+		},
+		"trace": {
+			nil,
+			log.Entry{Time: tm, Level: level.Error, Keyvals: []interface{}{
+				"trace_id", "4bf92f3577b34da6a3ce929d0e0e4736", "span_id", "00f067aa0ba902b7", "msg", "failed",
+			}},
+			`{"resourceLogs":[{"resource":{},"scopeLogs":[{"scope":{"name":"github.com/cobaltspeech/log"},"logRecords":[{` +
+				`"timeUnixNano":"1614852367890123456","observedTimeUnixNano":"1614852367890123456",` +
+				`"severityNumber":17,"severityText":"ERROR","body":{"stringValue":"failed"},` +
+				`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7"}]}]}]}`,
+		},
+		"invalid_trace": {
+			[]Option{WithTraceKeys("trace", "span"), WithMessageKey("message")},
+			log.Entry{Time: tm, Level: level.Trace, Keyvals: []interface{}{
+				"trace", "00000000000000000000000000000000", "span", "xyz", "msg", "m",
+			}},
+			`{"resourceLogs":[{"resource":{},"scopeLogs":[{"scope":{"name":"github.com/cobaltspeech/log"},"logRecords":[{` +
+				`"timeUnixNano":"1614852367890123456","observedTimeUnixNano":"1614852367890123456",` +
+				`"severityNumber":1,"severityText":"TRACE","attributes":[` +
+				`{"key":"trace","value":{"stringValue":"00000000000000000000000000000000"}},` +
+				`{"key":"span","value":{"stringValue":"xyz"}},{"key":"msg","value":{"stringValue":"m"}}]}]}]}]}`,
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := NewEncoder(tc.opts...).Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if strings.TrimSuffix(string(got), "\n") != tc.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", name, got, tc.want)
+		}
+	}
+
+	_, err := NewEncoder().Encode(&log.Entry{Keyvals: []interface{}{"key", failingTextMarshaler{}}})
+	if err == nil {
+		t.Error("expected an error for a failing TextMarshaler")
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLogger(&b, NewEncoder(), log.WithFilterLevel(level.All))
+	l.Debug("msg", "first")
+	l.Info("msg", "second")
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"severityText":"DEBUG","body":{"stringValue":"first"}`) {
+		t.Errorf("unexpected output %q", b.String())
+	}
+}
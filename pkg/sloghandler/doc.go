@@ -0,0 +1,26 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package sloghandler provides a log/slog Handler forwarding records to a
+// log.Logger, so that code using log/slog writes to the same Logger as the rest
+// of a program:
+//
+//	logger := slog.New(sloghandler.New(l))
+//	logger.Info("server started", "port", 8080)
+//
+// The package requires Go 1.21 or newer, and is empty when built with older
+// toolchains.
+package sloghandler
@@ -0,0 +1,189 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sloghandler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// filterer is implemented by Loggers whose filter level can be queried, such
+// as the log.LeveledLogger.
+type filterer interface {
+	GetFilterLevel() level.Level
+}
+
+// Handler is a slog.Handler forwarding records to a log.Logger.  The message of
+// the records is written with the log.MessageKey key, and their attributes as
+// keyvals, with the names of the groups prepended to their keys and separated
+// by dots, e.g. "request.method".  The time of the records is not forwarded,
+// since the Logger adds its own.  The frames of the Handler and of the log/slog
+// package are skipped by log.WithCaller, so that the caller reported is the
+// code calling the slog.Logger.
+//
+// The levels of the records are mapped to the closest level.Level no higher
+// than them: levels below slog.LevelDebug are mapped to level.Trace, and
+// slog.LevelWarn to level.Info.
+type Handler struct {
+	log      log.Logger
+	minLevel slog.Leveler
+
+	// keyvals are the attributes added by WithAttrs, and prefix the names of
+	// the groups opened by WithGroup, followed by a dot.
+	keyvals []interface{}
+	prefix  string
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithMinLevel returns an Option that sets the lowest level of the records
+// handled by the Handler.  By default, the Handler handles the records of all
+// levels enabled by the Logger.
+func WithMinLevel(lvl slog.Leveler) Option {
+	return func(h *Handler) {
+		h.minLevel = lvl
+	}
+}
+
+// New returns a new Handler forwarding records to l.  Its defaults can be
+// changed by providing Options.
+func New(l log.Logger, opts ...Option) *Handler {
+	h := &Handler{log: l}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Enabled implements the slog.Handler interface.  It reports whether the level
+// is at least the level set by WithMinLevel, and, if the Logger has a
+// GetFilterLevel method like the log.LeveledLogger, whether the corresponding
+// level.Level passes its filter.
+func (h *Handler) Enabled(_ context.Context, lvl slog.Level) bool {
+	if h.minLevel != nil && lvl < h.minLevel.Level() {
+		return false
+	}
+
+	if f, ok := h.log.(filterer); ok {
+		return f.GetFilterLevel()&toLevel(lvl) != 0
+	}
+
+	return true
+}
+
+// Handle implements the slog.Handler interface.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	keyvals := make([]interface{}, 0, len(h.keyvals)+2+2*r.NumAttrs()) //nolint:gomnd // message and attribute pairs
+	keyvals = append(keyvals, h.keyvals...)
+	keyvals = append(keyvals, log.MessageKey, r.Message)
+
+	r.Attrs(func(a slog.Attr) bool {
+		keyvals = appendAttr(keyvals, h.prefix, a)
+
+		return true
+	})
+
+	switch toLevel(r.Level) {
+	case level.Error:
+		h.log.Error(keyvals...)
+	case level.Info:
+		h.log.Info(keyvals...)
+	case level.Debug:
+		h.log.Debug(keyvals...)
+	default:
+		h.log.Trace(keyvals...)
+	}
+
+	return nil
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.keyvals = make([]interface{}, 0, len(h.keyvals)+2*len(attrs)) //nolint:gomnd // attribute pairs
+	h2.keyvals = append(h2.keyvals, h.keyvals...)
+
+	for _, a := range attrs {
+		h2.keyvals = appendAttr(h2.keyvals, h.prefix, a)
+	}
+
+	return &h2
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+
+	return &h2
+}
+
+// appendAttr appends the key and value of the attribute to keyvals, with the
+// prefix prepended to the key.  The attributes of groups are flattened, and
+// empty attributes are ignored, as required by slog.Handler.
+func appendAttr(keyvals []interface{}, prefix string, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+
+		for _, ga := range a.Value.Group() {
+			keyvals = appendAttr(keyvals, prefix, ga)
+		}
+
+		return keyvals
+	}
+
+	if a.Equal(slog.Attr{}) {
+		return keyvals
+	}
+
+	return append(keyvals, prefix+a.Key, a.Value.Any())
+}
+
+// toLevel returns the level.Level of the slog level.
+func toLevel(lvl slog.Level) level.Level {
+	switch {
+	case lvl >= slog.LevelError:
+		return level.Error
+	case lvl >= slog.LevelInfo:
+		return level.Info
+	case lvl >= slog.LevelDebug:
+		return level.Debug
+	default:
+		return level.Trace
+	}
+}
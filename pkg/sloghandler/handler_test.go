@@ -0,0 +1,100 @@
+//go:build go1.21
+// +build go1.21
+
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package sloghandler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestHandler(t *testing.T) {
+	var b bytes.Buffer
+
+	l := log.NewLeveledLogger(log.WithOutput(&b), log.WithTimestamp(log.TimestampNone), log.WithFilterLevel(level.All))
+	logger := slog.New(New(l))
+
+	logger.Debug("debug", "n", 1)
+	logger.Log(context.Background(), slog.LevelDebug-1, "trace")
+	logger.Warn("warn", slog.Duration("elapsed", time.Second))
+	logger.With("request_id", "r1").WithGroup("http").With("method", "GET").
+		Error("failed", slog.Group("response", "status", 500), slog.Group("", "inline", true), slog.Group("empty"))
+	logger.WithGroup("unused").Info("no attributes", slog.Attr{})
+
+	want := `debug {"msg":"debug","n":"1"}
+trace {"msg":"trace"}
+info  {"msg":"warn","elapsed":"1s"}
+error {"request_id":"r1","http.method":"GET","msg":"failed","http.response.status":"500","http.inline":"true"}
+info  {"msg":"no attributes"}`
+
+	if got := strings.TrimSpace(b.String()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHandler_Enabled(t *testing.T) {
+	ctx := context.Background()
+
+	l := log.NewLeveledLogger(log.WithFilterLevel(level.Error | level.Info))
+
+	tests := map[string]struct {
+		handler *Handler
+		lvl     slog.Level
+		want    bool
+	}{
+		"filtered":       {New(l), slog.LevelDebug, false},
+		"warn":           {New(l), slog.LevelWarn, true},
+		"min_level":      {New(l, WithMinLevel(slog.LevelError)), slog.LevelWarn, false},
+		"other_logger":   {New(log.NewDiscardLogger()), slog.LevelDebug - 4, true},
+		"min_level_pass": {New(l, WithMinLevel(slog.LevelError)), slog.LevelError + 1, true},
+	}
+
+	for name, tc := range tests {
+		if got := tc.handler.Enabled(ctx, tc.lvl); got != tc.want {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestHandler_caller(t *testing.T) {
+	var b bytes.Buffer
+
+	l := log.NewLeveledLogger(log.WithOutput(&b), log.WithTimestamp(log.TimestampNone), log.WithCaller())
+	logger := slog.New(New(l)).With("n", 1)
+
+	_, file, line, _ := runtime.Caller(0)
+	logger.Info("hello")
+
+	want := fmt.Sprintf(`info  {"caller":"%s/%s:%d","n":"1","msg":"hello"}`,
+		filepath.Base(filepath.Dir(file)), filepath.Base(file), line+1)
+
+	if got := strings.TrimSpace(b.String()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package syslog sends log messages to syslog servers, formatted according to
+// RFC 5424.  Use NewLogger to create a LeveledLogger sending messages to a
+// Writer returned by Dial:
+//
+//	w, err := syslog.Dial("tcp", "logs.example.com:514", syslog.WithFacility(syslog.Local0))
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	logger := syslog.NewLogger(w, log.WithFilterLevel(level.All))
+package syslog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/logmap"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// Facility is the syslog facility of the messages, which is combined with their
+// severity to form their priority.
+type Facility int
+
+// Facilities defined by RFC 5424.
+const (
+	Kern Facility = iota
+	User
+	Mail
+	Daemon
+	Auth
+	Syslog
+	LPR
+	News
+	UUCP
+	Cron
+	AuthPriv
+	FTP
+	NTP
+	Audit
+	Alert
+	Clock
+	Local0
+	Local1
+	Local2
+	Local3
+	Local4
+	Local5
+	Local6
+	Local7
+)
+
+// Severities of RFC 5424 used for the levels of the messages.  Trace messages
+// have the debug severity, like Debug messages.
+const (
+	severityError = 3
+	severityInfo  = 6
+	severityDebug = 7
+)
+
+// DefaultSDID is the SD-ID of the structured data element holding the keyvals
+// of the messages, unless changed with WithSDID.  It uses the enterprise number
+// reserved for documentation by RFC 5612.
+const DefaultSDID = "fields@32473"
+
+// nilValue is the value of the header fields and structured data of RFC 5424
+// messages that have no value.
+const nilValue = "-"
+
+// Maximum lengths of the header fields and parameter names.
+const (
+	maxHostname  = 255
+	maxAppName   = 48
+	maxProcID    = 128
+	maxParamName = 32
+)
+
+// timeFormat is the format of the timestamp of RFC 5424 messages, which may
+// have up to microsecond precision.
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// config holds the settings of the Encoder and Writer.
+type config struct {
+	facility    Facility
+	hostname    string
+	appName     string
+	procID      string
+	sdID        string
+	messageKey  string
+	jsonMessage bool
+}
+
+// Option configures an Encoder, or the Encoder and Writer returned by Dial.
+type Option func(*config)
+
+// WithFacility returns an Option that sets the facility of the messages, User
+// by default.
+func WithFacility(f Facility) Option {
+	return func(c *config) {
+		c.facility = f
+	}
+}
+
+// WithHostname returns an Option that sets the HOSTNAME field of the messages,
+// the name returned by os.Hostname by default.
+func WithHostname(hostname string) Option {
+	return func(c *config) {
+		c.hostname = hostname
+	}
+}
+
+// WithAppName returns an Option that sets the APP-NAME field of the messages,
+// the base name of the executable by default.
+func WithAppName(name string) Option {
+	return func(c *config) {
+		c.appName = name
+	}
+}
+
+// WithSDID returns an Option that sets the SD-ID of the structured data
+// element holding the keyvals of the messages, DefaultSDID by default.  Custom
+// SD-IDs must have the form name@enterprise-number.
+func WithSDID(id string) Option {
+	return func(c *config) {
+		c.sdID = id
+	}
+}
+
+// WithMessageKey returns an Option that sets the key of the field used as the
+// MSG part of the messages, log.MessageKey by default.  It must match the name
+// given to log.WithKeyNames, if any.
+func WithMessageKey(key string) Option {
+	return func(c *config) {
+		c.messageKey = key
+	}
+}
+
+// WithJSONMessage returns an Option that writes the keyvals of the messages as
+// a JSON object in the MSG part, without structured data, for servers that do
+// not parse structured data.
+func WithJSONMessage() Option {
+	return func(c *config) {
+		c.jsonMessage = true
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		facility:   User,
+		procID:     strconv.Itoa(os.Getpid()),
+		sdID:       DefaultSDID,
+		messageKey: log.MessageKey,
+	}
+
+	c.hostname, _ = os.Hostname()
+
+	if len(os.Args) > 0 {
+		c.appName = filepath.Base(os.Args[0])
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// Encoder is a log.Encoder formatting messages according to RFC 5424:
+//
+//	<14>1 2021-03-04T10:06:07.890123Z host server 1234 - [fields@32473 port="8080"] server started
+//
+// By default, the field with the log.MessageKey key is the MSG part, and the
+// others are the parameters of a structured data element, with their names
+// restricted to the characters allowed by RFC 5424.
+type Encoder struct {
+	config
+}
+
+// NewEncoder returns a new Encoder.  Its defaults can be changed by providing
+// Options.
+func NewEncoder(opts ...Option) *Encoder {
+	return &Encoder{newConfig(opts)}
+}
+
+// Encode implements the log.Encoder interface.
+func (enc *Encoder) Encode(e *log.Entry) ([]byte, error) {
+	var sb strings.Builder
+
+	sb.WriteByte('<')
+	sb.WriteString(strconv.Itoa(int(enc.facility)*8 + severity(e.Level))) //nolint:gomnd // RFC 5424 priority
+	sb.WriteString(">1 ")
+	sb.WriteString(e.Time.Format(timeFormat))
+	sb.WriteByte(' ')
+	sb.WriteString(headerField(enc.hostname, maxHostname))
+	sb.WriteByte(' ')
+	sb.WriteString(headerField(enc.appName, maxAppName))
+	sb.WriteByte(' ')
+	sb.WriteString(headerField(enc.procID, maxProcID))
+	sb.WriteString(" - ")
+
+	ms := logmap.FromKeyvals(e.Keyvals...)
+
+	if enc.jsonMessage {
+		msg, err := ms.JSONString()
+		if err != nil {
+			return nil, err
+		}
+
+		sb.WriteString(nilValue + " ")
+		sb.WriteString(strings.TrimSuffix(msg, "\n"))
+
+		return []byte(sb.String()), nil
+	}
+
+	msg, err := enc.writeStructuredData(&sb, ms)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg != "" {
+		sb.WriteByte(' ')
+		sb.WriteString(msg)
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// writeStructuredData writes the fields as a structured data element, and
+// returns the value of the message field.
+func (enc *Encoder) writeStructuredData(sb *strings.Builder, ms logmap.MapSlice) (string, error) {
+	var (
+		msg    string
+		params int
+	)
+
+	for _, item := range ms {
+		value, err := logmap.TextFromValue(item.Value)
+		if err != nil {
+			return "", err
+		}
+
+		if item.Key == enc.messageKey && msg == "" {
+			msg = value
+
+			continue
+		}
+
+		if params == 0 {
+			sb.WriteByte('[')
+			sb.WriteString(enc.sdID)
+		}
+
+		params++
+
+		sb.WriteByte(' ')
+		sb.WriteString(paramName(item.Key))
+		sb.WriteString(`="`)
+		sb.WriteString(paramValue(value))
+		sb.WriteByte('"')
+	}
+
+	if params == 0 {
+		sb.WriteString(nilValue)
+	} else {
+		sb.WriteByte(']')
+	}
+
+	return msg, nil
+}
+
+// severity returns the severity of the level.
+func severity(lvl level.Level) int {
+	switch lvl {
+	case level.Error:
+		return severityError
+	case level.Info:
+		return severityInfo
+	default:
+		return severityDebug
+	}
+}
+
+// headerField returns the value of a header field, made of up to limit
+// printable ASCII characters, or the nil value if it is empty.
+func headerField(s string, limit int) string {
+	s = printableASCII(s, limit)
+	if s == "" {
+		return nilValue
+	}
+
+	return s
+}
+
+// paramName returns the name of a structured data parameter, replacing the
+// characters not allowed by RFC 5424 with underscores.
+func paramName(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+
+		return r
+	}, key)
+
+	if key = printableASCII(key, maxParamName); key == "" {
+		return "_"
+	}
+
+	return key
+}
+
+// printableASCII replaces the characters of s that are not printable ASCII,
+// or are spaces, with underscores, and truncates it to limit bytes.
+func printableASCII(s string, limit int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r >= utf8.RuneSelf-1 {
+			return '_'
+		}
+
+		return r
+	}, s)
+
+	if len(s) > limit {
+		s = s[:limit]
+	}
+
+	return s
+}
+
+// paramValueEscaper escapes the characters of structured data parameter values
+// as required by RFC 5424.
+var paramValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// paramValue escapes the structured data parameter value.
+func paramValue(value string) string {
+	return paramValueEscaper.Replace(value)
+}
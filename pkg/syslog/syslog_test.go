@@ -0,0 +1,85 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package syslog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+type failingTextMarshaler struct{}
+
+func (failingTextMarshaler) MarshalText() ([]byte, error) {
+	return nil, errors.New("marshal failure")
+}
+
+func TestEncoder(t *testing.T) {
+	tm := time.Date(2021, 3, 4, 10, 6, 7, 890123456, time.UTC)
+	opts := []Option{WithHostname("host"), WithAppName("server"), func(c *config) { c.procID = "1234" }}
+
+	tests := map[string]struct {
+		opts  []Option
+		entry log.Entry
+		want  string
+	}{
+		"structured": {
+			opts,
+			log.Entry{Time: tm, Level: level.Info, Keyvals: []interface{}{"port", 8080, "msg", "server started"}},
+			`<14>1 2021-03-04T10:06:07.890123Z host server 1234 - [fields@32473 port="8080"] server started`,
+		},
+		"escaping": {
+			append(opts, WithFacility(Local0), WithSDID("app@12345")),
+			log.Entry{Time: tm, Level: level.Error, Keyvals: []interface{}{"a key=x", `"quoted" \ [x]`, "msg", "failed"}},
+			`<131>1 2021-03-04T10:06:07.890123Z host server 1234 - [app@12345 a_key_x="\"quoted\" \\ [x\]"] failed`,
+		},
+		"no_fields": {
+			opts,
+			log.Entry{Time: tm, Level: level.Trace, Keyvals: []interface{}{"msg", "tick"}},
+			`<15>1 2021-03-04T10:06:07.890123Z host server 1234 - - tick`,
+		},
+		"no_message": {
+			append(opts, WithHostname(""), WithMessageKey("message")),
+			log.Entry{Time: tm, Level: level.Debug, Keyvals: []interface{}{"msg", "not the message"}},
+			`<15>1 2021-03-04T10:06:07.890123Z - server 1234 - [fields@32473 msg="not the message"]`,
+		},
+		"json": {
+			append(opts, WithJSONMessage(), WithFacility(Daemon)),
+			log.Entry{Time: tm, Level: level.Info, Keyvals: []interface{}{"msg", "server started", "port", 8080}},
+			`<30>1 2021-03-04T10:06:07.890123Z host server 1234 - - {"msg":"server started","port":"8080"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		got, err := NewEncoder(tc.opts...).Encode(&tc.entry)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+
+		if string(got) != tc.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", name, got, tc.want)
+		}
+	}
+
+	_, err := NewEncoder().Encode(&log.Entry{Keyvals: []interface{}{"key", failingTextMarshaler{}}})
+	if err == nil {
+		t.Error("expected an error for a failing TextMarshaler")
+	}
+}
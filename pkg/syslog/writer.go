@@ -0,0 +1,166 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strconv"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/internal/netconn"
+)
+
+// ErrNoLocalSyslog is returned by Dial when no local syslog socket was found.
+var ErrNoLocalSyslog = errors.New("syslog: no local syslog socket found")
+
+// ErrClosed is returned by Write and Close after Close.
+var ErrClosed = errors.New("syslog: writer closed")
+
+// localSockets are the paths of the sockets of local syslog servers.
+var localSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// framing enumerates the ways messages are delimited over a connection.
+type framing byte
+
+const (
+	// frameNone sends each message as a datagram.
+	frameNone framing = iota
+
+	// frameOctetCount prefixes each message with its length, as described by
+	// RFC 6587 for syslog over TCP.
+	frameOctetCount
+
+	// frameNewline terminates each message with a newline, as expected by
+	// local syslog servers listening on unix stream sockets.
+	frameNewline
+)
+
+// Writer is an io.Writer sending each write to a syslog server as a message.
+// It is meant to be used by a LeveledLogger created by NewLogger, which writes
+// the messages formatted by the Encoder of the Writer.
+//
+// If sending a message fails, for instance because the server restarted, the
+// Writer reconnects and sends the message again once.  A Writer is safe for
+// concurrent use.
+type Writer struct {
+	network string
+	raddr   string
+	encoder *Encoder
+	conn    *netconn.Conn
+}
+
+// Dial returns a new Writer connected to the syslog server at the given
+// address.  The network may be "udp", "tcp", "unixgram" or "unix", or their
+// variants accepted by net.Dial, or "" to connect to the local syslog server
+// through its unix socket.  Messages sent over TCP are framed with octet
+// counting as described by RFC 6587, and messages sent over unix stream
+// sockets are terminated by a newline, as expected by local syslog servers.
+//
+// The Options configure the format of the messages, see NewEncoder.
+func Dial(network, raddr string, opts ...Option) (*Writer, error) {
+	w := &Writer{network: network, raddr: raddr, encoder: NewEncoder(opts...)}
+
+	conn, err := netconn.Dial(w.dial, ErrClosed)
+	if err != nil {
+		return nil, err
+	}
+
+	w.conn = conn
+
+	return w, nil
+}
+
+// NewLogger returns a new LeveledLogger sending its messages to w, formatted
+// by the Encoder of w.  The Options are applied before those setting the
+// output and the Encoder.
+func NewLogger(w *Writer, opts ...log.Option) *log.LeveledLogger {
+	opts = append(opts, log.WithOutput(w), log.WithEncoder(w.Encoder()), log.WithTimestamp(log.TimestampNone))
+
+	return log.NewLeveledLogger(opts...)
+}
+
+// Encoder returns the Encoder formatting the messages sent by w.
+func (w *Writer) Encoder() *Encoder {
+	return w.encoder
+}
+
+// Write sends p as a message, without its trailing newline.
+func (w *Writer) Write(p []byte) (int, error) {
+	msg := bytes.TrimSuffix(p, []byte{'\n'})
+
+	err := w.conn.Send(func(conn net.Conn) error {
+		return send(conn, msg)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the connection to the server.  The Writer cannot be used
+// afterwards.
+func (w *Writer) Close() error {
+	return w.conn.Close()
+}
+
+// dial connects to the server.
+func (w *Writer) dial() (net.Conn, error) {
+	if w.network != "" {
+		return net.DialTimeout(w.network, w.raddr, netconn.DialTimeout)
+	}
+
+	for _, path := range localSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.DialTimeout(network, path, netconn.DialTimeout); err == nil {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, ErrNoLocalSyslog
+}
+
+// send sends the message over the connection, framed as required by its
+// network.
+func send(conn net.Conn, msg []byte) error {
+	switch framingOf(conn.RemoteAddr().Network()) {
+	case frameOctetCount:
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	case frameNewline:
+		msg = append(msg[:len(msg):len(msg)], '\n')
+	case frameNone:
+	}
+
+	_, err := conn.Write(msg)
+
+	return err
+}
+
+// framingOf returns the framing of messages sent over the network.
+func framingOf(network string) framing {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return frameOctetCount
+	case "unix":
+		return frameNewline
+	default:
+		return frameNone
+	}
+}
@@ -0,0 +1,287 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cobaltspeech/log"
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+var testOptions = []Option{WithHostname("host"), WithAppName("test")}
+
+// readFrame reads a message framed with octet counting.
+func readFrame(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	n, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := strconv.Atoi(strings.TrimSuffix(n, " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return string(msg)
+}
+
+func TestWriter_udp(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	w, err := Dial("udp", conn.LocalAddr().String(), testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	NewLogger(w).Info("msg", "hello", "n", 1)
+
+	buf := make([]byte, 1024)
+
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(buf[:n])
+	if !strings.HasPrefix(got, "<14>1 ") || !strings.HasSuffix(got, ` host test `+strconv.Itoa(os.Getpid())+` - [fields@32473 n="1"] hello`) {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestWriter_tcpReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	w, err := Dial("tcp", ln.Addr().String(), testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	l := NewLogger(w, log.WithFilterLevel(level.All))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug("msg", "first")
+	l.Error("msg", "second")
+
+	r := bufio.NewReader(conn)
+
+	for _, want := range []string{"<15>1", "<11>1"} {
+		if got := readFrame(t, r); !strings.HasPrefix(got, want) {
+			t.Errorf("got %q, want prefix %q", got, want)
+		}
+	}
+
+	// The server drops the connection; the next messages are sent over a new
+	// connection, possibly after a write to the closed connection succeeded.
+	conn.Close()
+
+	accepted := make(chan net.Conn)
+
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+
+	for i := 0; ; i++ {
+		l.Info("msg", "after", "i", i)
+
+		select {
+		case c := <-accepted:
+			defer c.Close()
+
+			if got := readFrame(t, bufio.NewReader(c)); !strings.HasSuffix(got, "after") {
+				t.Errorf("unexpected message %q", got)
+			}
+
+			return
+		default:
+		}
+
+		if i > 1000 {
+			t.Fatal("writer did not reconnect")
+		}
+	}
+}
+
+func TestWriter_unixgram(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log")
+
+	conn, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Skipf("unixgram sockets not supported: %v", err)
+	}
+
+	defer conn.Close()
+
+	w, err := Dial("unixgram", path, append(testOptions, WithJSONMessage())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("message\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(buf[:n]); got != "message" {
+		t.Errorf("got %q, want %q", got, "message")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriter_unixStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log")
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+
+	defer ln.Close()
+
+	w, err := Dial("unix", path, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+
+	for _, msg := range []string{"first", "second"} {
+		if _, err := w.Write([]byte(msg + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(conn)
+
+	for _, want := range []string{"first\n", "second\n"} {
+		got, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestWriter_closed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer ln.Close()
+
+	w, err := Dial("tcp", ln.Addr().String(), testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("message\n")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write after Close: got %v, want %v", err, ErrClosed)
+	}
+
+	if err := w.Close(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Close after Close: got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestDial_error(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, err := Dial("tcp", addr); err == nil {
+		t.Error("expected an error when the server is not listening")
+	}
+}
@@ -17,10 +17,12 @@
 package testinglog
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
@@ -62,6 +64,9 @@ type Logger struct {
 	// If non-nil, ignorer is used to choose log message fields whose values should be ignored
 	// during comparison.
 	ignorer FieldIgnoreFunc
+
+	// nativeTypes is whether values are written with their native JSON types.
+	nativeTypes bool
 }
 
 // TestRunner is an interface for an object that can receive reports of test failure and logging. It
@@ -210,6 +215,18 @@ func WithIgnoreOrder() LoggerOption {
 	}
 }
 
+// WithNativeTypes sets the Logger to write values that have a native JSON representation as such,
+// like a LeveledLogger using a JSONEncoder created with the log.WithNativeTypes option. That is,
+// "count", 12 is written as "count":12 rather than "count":"12". Values are then compared by their
+// JSON representation, so that e.g. 1.0 and 1 in a truth file are equivalent.
+func WithNativeTypes() LoggerOption {
+	return func(l *Logger) error {
+		l.nativeTypes = true
+
+		return nil
+	}
+}
+
 // FieldIgnoreFunc is a function that decides which fields' values should be ignored in a log
 // message.
 type FieldIgnoreFunc func(fields map[string]string) []string
@@ -297,7 +314,12 @@ func (l *Logger) error(err error) {
 // compare checks whether the provided log data were expected, reporting any differences to
 // l.runner. It also increments the internal log message counter.
 func (l *Logger) compare(lvl level.Level, keyvals ...interface{}) {
-	ms := logmap.FromKeyvals(keyvals...)
+	fromKeyvals := logmap.FromKeyvals
+	if l.nativeTypes {
+		fromKeyvals = logmap.FromKeyvalsTyped
+	}
+
+	ms := fromKeyvals(keyvals...)
 
 	exp, err := ms.JSONString()
 	if err != nil {
@@ -406,9 +428,9 @@ func (l *Logger) getCurrent() string {
 }
 
 // cmp compares the log lines using == or by checking each field individually if the ignorer is
-// non-nil.
+// non-nil or values are written with their native types.
 func (l *Logger) cmp(hyp, exp string, expLvl level.Level, expMap logmap.MapSlice) bool {
-	if l.ignorer == nil {
+	if l.ignorer == nil && !l.nativeTypes {
 		return hyp == exp
 	}
 
@@ -434,7 +456,10 @@ func (l *Logger) cmp(hyp, exp string, expLvl level.Level, expMap logmap.MapSlice
 		return false
 	}
 
-	keysToIgnore := l.ignorer(hypMap.ToStringMap())
+	var keysToIgnore []string
+	if l.ignorer != nil {
+		keysToIgnore = l.ignorer(hypMap.ToStringMap())
+	}
 
 	for i := range hypMap {
 		if hypMap[i].Key != expMap[i].Key {
@@ -446,9 +471,7 @@ func (l *Logger) cmp(hyp, exp string, expLvl level.Level, expMap logmap.MapSlice
 			continue
 		}
 
-		// Values in hypMap were unmarshaled from JSON, so they're strings. This is not necessarily
-		// the case with values in expMap, so we need to convert them to strings.
-		if hypMap[i].Value != logmap.StringFromValue(expMap[i].Value) {
+		if !l.valueEqual(hypMap[i].Value, expMap[i].Value) {
 			return false
 		}
 	}
@@ -456,6 +479,29 @@ func (l *Logger) cmp(hyp, exp string, expLvl level.Level, expMap logmap.MapSlice
 	return true
 }
 
+// valueEqual compares a value unmarshaled from a truth log line with a logged value.
+func (l *Logger) valueEqual(hyp, exp interface{}) bool {
+	if !l.nativeTypes {
+		// Values in hypMap were unmarshaled from JSON, so they're strings. This is not necessarily
+		// the case with values in expMap, so we need to convert them to strings.
+		return hyp == logmap.StringFromValue(exp)
+	}
+
+	// With native types, hyp may be any value unmarshaled from JSON. We round trip exp through
+	// JSON so that both values have the same representation.
+	b, err := json.Marshal(exp)
+	if err != nil {
+		return false
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(hyp, v)
+}
+
 func sliceContains(slice []string, item string) bool {
 	for i := range slice {
 		if slice[i] == item {
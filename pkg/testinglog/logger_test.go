@@ -834,6 +834,83 @@ func TestWithFieldIgnorer(t *testing.T) { // nolint: funlen // Tests are just lo
 	}
 }
 
+func TestWithNativeTypes(t *testing.T) {
+	t.Parallel()
+
+	idIgnorer := WithIgnoredFields(map[string][]string{"Generated an ID.": {"id"}})
+
+	tests := map[string]struct {
+		in         []testingLogMsg
+		opts       []LoggerOption
+		hyp        string
+		expectFail bool
+	}{
+		"match": {
+			opts: []LoggerOption{idIgnorer},
+			in: []testingLogMsg{
+				{level.Info, []interface{}{"msg", "Typed values.", "count", 12, "ratio", 0.5, "list", []int{0, 1, 2, 3}}},
+				{level.Debug, []interface{}{"msg", "Generated an ID.", "id", 42}},
+			},
+			hyp: strings.Join([]string{
+				`info  {"msg":"Typed values.","count":12,"ratio":0.5,"list":[0,1,2,3]}`,
+				`debug {"msg":"Generated an ID.","id":42}`,
+			}, "\n"),
+		},
+		"no_ignorer": {
+			in: []testingLogMsg{
+				{level.Info, []interface{}{"msg", "Typed values.", "count", 12, "ratio", 0.5, "list", []int{0, 1, 2, 3}}},
+				{level.Debug, []interface{}{"msg", "Generated an ID.", "id", 7}},
+			},
+			hyp: strings.Join([]string{
+				`info  {"msg":"Typed values.","count":12,"ratio":0.5,"list":[0,1,2,3]}`,
+				`debug {"msg":"Generated an ID.","id":7}`,
+			}, "\n"),
+		},
+		"wrong_type": {
+			opts: []LoggerOption{idIgnorer},
+			in: []testingLogMsg{
+				{level.Info, []interface{}{"msg", "Typed values.", "count", "12", "ratio", 0.5, "list", []int{0, 1, 2, 3}}},
+				{level.Debug, []interface{}{"msg", "Generated an ID.", "id", 42}},
+			},
+			hyp: strings.Join([]string{
+				`info  {"msg":"Typed values.","count":"12","ratio":0.5,"list":[0,1,2,3]}`,
+				"unexpected log message (-want +got):",
+				"  strings.Join({",
+				"  	`" + `info  {"msg":"Typed values.","count":` + "`,",
+				"+ 	`" + `"` + "`,",
+				`  	"12",`,
+				"- 	`" + `,"ratio":0.50` + "`,",
+				"+ 	`" + `","ratio":0.5` + "`,",
+				"  	`" + `,"list":[0,1,2,3]}` + "`,",
+				`  }, "")`,
+				`debug {"msg":"Generated an ID.","id":42}`,
+			}, "\n"),
+			expectFail: true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := fakeRunner{}
+			opts := append([]LoggerOption{WithTruthFile(filepath.Join("testdata", "typed.log")), WithNativeTypes()}, tc.opts...)
+
+			logger, err := NewLogger(&runner, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writeLogMsgs(logger, tc.in)
+			logger.Done()
+
+			runner.compareOutput(t, tc.hyp, tc.expectFail)
+		})
+	}
+}
+
 // TestWithIgnoreOrder checks whether tests:
 // 	- can pass when WithIgnoreOrder option is enabled and logs are not received
 //    in the same order as in the truth file.
@@ -0,0 +1,329 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// SampledKey is the key of the field holding the message of the entries
+// dropped by a Logger returned by Sampled, in the summary it logs at the end of
+// each interval.
+const SampledKey = "sampled_msg"
+
+// DroppedKey is the key of the field holding the number of entries dropped by
+// a Logger returned by Sampled, in the summary it logs at the end of each
+// interval.
+const DroppedKey = "dropped"
+
+// Default sampling rate used by Sampled for the levels without a
+// WithSampleRate option.
+const (
+	DefaultSampleFirst      = 100
+	DefaultSampleThereafter = 100
+)
+
+// SamplerOption configures a Logger returned by Sampled.
+type SamplerOption func(*SampledLogger)
+
+// WithSampleRate returns a SamplerOption that sets the sampling rate of the
+// given levels, which may be combined, e.g. level.Debug|level.Trace.  During
+// each interval, the first entries with the same level and message are logged,
+// then only every thereafter-th entry.  If thereafter is zero, all the entries
+// after the first ones are dropped.  If first is negative, the entries of the
+// levels are never sampled.
+func WithSampleRate(lvls level.Level, first, thereafter int) SamplerOption {
+	return func(s *SampledLogger) {
+		for _, lvl := range []level.Level{level.Error, level.Info, level.Debug, level.Trace} {
+			if lvls&lvl != 0 {
+				s.rates[lvl] = sampleRate{first, thereafter}
+			}
+		}
+	}
+}
+
+// Sampled returns a new Logger that bounds the volume of repetitive entries
+// logged to l, e.g. by a hot loop.  Entries are grouped by level and by the
+// value of their MessageKey field.  During each interval, the first entries of
+// a group are logged, and then only a fraction of them, according to the rate
+// set with WithSampleRate; all levels use DefaultSampleFirst and
+// DefaultSampleThereafter by default.
+//
+// At the end of the interval, if entries of a group were dropped, a summary is
+// logged at the level of the group, without caller, with the SampledKey and
+// DroppedKey fields holding the message and the number of dropped entries.
+//
+// Stop must be called when the Logger is no longer used, so that its timer
+// does not log summaries afterwards.
+func Sampled(l Logger, interval time.Duration, opts ...SamplerOption) *SampledLogger {
+	s := &SampledLogger{
+		log:      l,
+		interval: interval,
+		now:      time.Now,
+		rates:    make(map[level.Level]sampleRate),
+		counts:   make(map[sampleKey]*sampleCount),
+	}
+
+	for _, lvl := range []level.Level{level.Error, level.Info, level.Debug, level.Trace} {
+		s.rates[lvl] = sampleRate{DefaultSampleFirst, DefaultSampleThereafter}
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+type sampleRate struct {
+	first      int
+	thereafter int
+}
+
+type sampleKey struct {
+	lvl level.Level
+	msg string
+}
+
+// sampleCount holds the entries of a group during its interval, which ends at
+// end.
+type sampleCount struct {
+	n       int
+	dropped int
+	end     time.Time
+}
+
+// SampledLogger is the Logger returned by Sampled.
+type SampledLogger struct {
+	log      Logger
+	interval time.Duration
+	now      func() time.Time
+	rates    map[level.Level]sampleRate
+
+	// mu protects the fields below.  A single timer ends the intervals of all
+	// the groups: it is armed for the end of the oldest one, and rearmed by
+	// expire for the next one.
+	mu      sync.Mutex
+	counts  map[sampleKey]*sampleCount
+	timer   *time.Timer
+	stopped bool
+}
+
+func (s *SampledLogger) loggerName() string {
+	if n, ok := s.log.(namer); ok {
+		return n.loggerName()
+	}
+
+	return ""
+}
+
+// Error implements the Logger interface.
+func (s *SampledLogger) Error(keyvals ...interface{}) {
+	if s.sample(level.Error, keyvals) {
+		s.log.Error(keyvals...)
+	}
+}
+
+// Info implements the Logger interface.
+func (s *SampledLogger) Info(keyvals ...interface{}) {
+	if s.sample(level.Info, keyvals) {
+		s.log.Info(keyvals...)
+	}
+}
+
+// Debug implements the Logger interface.
+func (s *SampledLogger) Debug(keyvals ...interface{}) {
+	if s.sample(level.Debug, keyvals) {
+		s.log.Debug(keyvals...)
+	}
+}
+
+// Trace implements the Logger interface.
+func (s *SampledLogger) Trace(keyvals ...interface{}) {
+	if s.sample(level.Trace, keyvals) {
+		s.log.Trace(keyvals...)
+	}
+}
+
+// Stop stops the timer ending the intervals, and logs the summaries of the
+// entries dropped so far.  Entries logged after Stop are not sampled.  It is
+// safe to call Stop more than once.
+func (s *SampledLogger) Stop() {
+	s.mu.Lock()
+
+	s.stopped = true
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	summaries := s.summaries(func(*sampleCount) bool { return true })
+	s.mu.Unlock()
+
+	for _, kvs := range summaries {
+		logAt(s.log, kvs.lvl, kvs.keyvals)
+	}
+}
+
+// sample reports whether the entry must be logged.  The first entry of a group
+// starts its interval, and arms the timer if no other interval is running.
+func (s *SampledLogger) sample(lvl level.Level, keyvals []interface{}) bool {
+	rate := s.rates[lvl]
+	if rate.first < 0 {
+		return true
+	}
+
+	key := sampleKey{lvl, messageFromKeyvals(keyvals)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return true
+	}
+
+	c, ok := s.counts[key]
+	if !ok {
+		c = &sampleCount{end: s.now().Add(s.interval)}
+		s.counts[key] = c
+
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.interval, s.expire)
+		}
+	}
+
+	c.n++
+
+	if c.n <= rate.first || (rate.thereafter > 0 && (c.n-rate.first)%rate.thereafter == 0) {
+		return true
+	}
+
+	c.dropped++
+
+	return false
+}
+
+// expire ends the intervals of the groups that are over, logging a summary for
+// those with dropped entries, and rearms the timer for the next interval to
+// end.
+func (s *SampledLogger) expire() {
+	s.mu.Lock()
+
+	if s.stopped {
+		s.mu.Unlock()
+
+		return
+	}
+
+	now := s.now()
+	summaries := s.summaries(func(c *sampleCount) bool { return !c.end.After(now) })
+
+	s.timer = nil
+
+	var next time.Time
+
+	for _, c := range s.counts {
+		if next.IsZero() || c.end.Before(next) {
+			next = c.end
+		}
+	}
+
+	if !next.IsZero() {
+		s.timer = time.AfterFunc(next.Sub(now), s.expire)
+	}
+
+	s.mu.Unlock()
+
+	for _, kvs := range summaries {
+		logAt(s.log, kvs.lvl, kvs.keyvals)
+	}
+}
+
+// sampleSummary is the summary of the entries of a group dropped during its
+// interval.
+type sampleSummary struct {
+	lvl     level.Level
+	keyvals []interface{}
+}
+
+// summaries removes the groups for which over returns true, and returns the
+// summaries of those with dropped entries, ordered by the end of their
+// interval, level and message.  It must be called with the lock held.
+func (s *SampledLogger) summaries(over func(*sampleCount) bool) []sampleSummary {
+	var keys []sampleKey
+
+	for key, c := range s.counts {
+		if over(c) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := s.counts[keys[i]], s.counts[keys[j]]
+
+		switch {
+		case !ci.end.Equal(cj.end):
+			return ci.end.Before(cj.end)
+		case keys[i].lvl != keys[j].lvl:
+			return keys[i].lvl > keys[j].lvl
+		default:
+			return keys[i].msg < keys[j].msg
+		}
+	})
+
+	var summaries []sampleSummary
+
+	for _, key := range keys {
+		c := s.counts[key]
+		delete(s.counts, key)
+
+		if c.dropped == 0 {
+			continue
+		}
+
+		summaries = append(summaries, sampleSummary{key.lvl, []interface{}{
+			MessageKey, "log entries dropped by sampling",
+			SampledKey, key.msg,
+			DroppedKey, c.dropped,
+			CallerKey, noCaller,
+		}})
+	}
+
+	return summaries
+}
+
+// messageFromKeyvals returns the value of the first MessageKey field of
+// keyvals, formatted as a string.
+func messageFromKeyvals(keyvals []interface{}) string {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if k, ok := keyvals[i].(string); ok && k == MessageKey {
+			if s, ok := keyvals[i+1].(string); ok {
+				return s
+			}
+
+			return fmt.Sprint(keyvals[i+1])
+		}
+	}
+
+	return ""
+}
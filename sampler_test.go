@@ -0,0 +1,161 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestSampled(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithFilterLevel(level.All))
+
+	s := Sampled(l, time.Hour,
+		WithSampleRate(level.Debug|level.Trace, 2, 3),
+		WithSampleRate(level.Error, -1, 0),
+		WithSampleRate(level.Info, 1, 0))
+
+	now := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		s.Debug("msg", "loop", "i", i)
+		s.Trace("msg", "loop", "i", i)
+		s.Error("msg", "loop", "i", i)
+	}
+
+	s.Info("msg", "info", "i", 0)
+	s.Info("msg", "info", "i", 1)
+	s.Info("msg", "other", "i", 2)
+
+	// End the intervals without waiting for the timer.
+	now = now.Add(time.Hour)
+	s.expire()
+
+	s.Info("msg", "info", "i", 3)
+
+	want := `debug {"msg":"loop","i":"0"}
+trace {"msg":"loop","i":"0"}
+error {"msg":"loop","i":"0"}
+debug {"msg":"loop","i":"1"}
+trace {"msg":"loop","i":"1"}
+error {"msg":"loop","i":"1"}
+error {"msg":"loop","i":"2"}
+error {"msg":"loop","i":"3"}
+debug {"msg":"loop","i":"4"}
+trace {"msg":"loop","i":"4"}
+error {"msg":"loop","i":"4"}
+error {"msg":"loop","i":"5"}
+info  {"msg":"info","i":"0"}
+info  {"msg":"other","i":"2"}
+info  {"msg":"log entries dropped by sampling","sampled_msg":"info","dropped":"1"}
+debug {"msg":"log entries dropped by sampling","sampled_msg":"loop","dropped":"3"}
+trace {"msg":"log entries dropped by sampling","sampled_msg":"loop","dropped":"3"}
+info  {"msg":"info","i":"3"}
+`
+
+	if got := b.String(); got != want {
+		t.Errorf("Sampled: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSampled_interval(t *testing.T) {
+	var (
+		mu sync.Mutex
+		b  bytes.Buffer
+	)
+
+	l := NewLeveledLogger(WithLogger(log.New(&lockedWriter{&mu, &b}, "", 0)))
+	s := Sampled(l, 10*time.Millisecond, WithSampleRate(level.Info, 1, 0))
+
+	s.Info("msg", "tick")
+	s.Info("msg", "tick")
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		mu.Lock()
+		got := b.String()
+		mu.Unlock()
+
+		if strings.Contains(got, `"dropped":"1"`) {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("no summary logged: %s", got)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Info("msg", "tick")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if n := strings.Count(b.String(), `"msg":"tick"`); n != 2 {
+		t.Errorf("got %d entries, want 2:\n%s", n, b.String())
+	}
+}
+
+func TestSampled_Stop(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithCaller())
+
+	s := Sampled(l, time.Hour, WithSampleRate(level.Info, 1, 0))
+
+	s.Info("msg", "tick")
+	s.Info("msg", "tick")
+	s.Stop()
+	s.Stop()
+	s.Info("msg", "tick")
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), b.String())
+	}
+
+	want := `info  {"msg":"log entries dropped by sampling","sampled_msg":"tick","dropped":"1"}`
+	if lines[1] != want {
+		t.Errorf("got summary %s, want %s", lines[1], want)
+	}
+
+	if !strings.Contains(lines[2], "/sampler_test.go:") {
+		t.Errorf("entry logged after Stop: got %s, want a caller in this file", lines[2])
+	}
+}
+
+// lockedWriter serializes the writes and reads of a buffer shared with a timer.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.w.Write(p)
+}
@@ -0,0 +1,100 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// StacktraceKey is the default key of the field added by the WithStacktrace
+// option.  See WithKeyNames.
+const StacktraceKey = "stack"
+
+// defaultStacktraceDepth is the number of frames of stack traces unless
+// changed by WithStacktraceDepth.
+const defaultStacktraceDepth = 32
+
+// WithStacktrace returns an Option that configures the LeveledLogger to add a
+// StacktraceKey field to Error messages, holding the stack trace of the
+// goroutine that called the logging method.  The frames of the Loggers of this
+// package and of helper functions (see Helper) are trimmed from the top of the
+// stack trace, and at most 32 frames are included.
+//
+// Each frame is written as the function name followed by a line holding a tab,
+// the file and the line number, as in the stack traces of panics.
+func WithStacktrace() Option {
+	return func(l *LeveledLogger) {
+		if l.stackLevel == level.None {
+			l.stackLevel = level.Error
+		}
+
+		if l.stackDepth == 0 {
+			l.stackDepth = defaultStacktraceDepth
+		}
+	}
+}
+
+// WithStacktraceLevel is like WithStacktrace, but adds stack traces to the
+// messages at or above the given level, e.g. level.Info adds them to Info and
+// Error messages.
+func WithStacktraceLevel(lvl level.Level) Option {
+	return func(l *LeveledLogger) {
+		WithStacktrace()(l)
+		l.stackLevel = lvl
+	}
+}
+
+// WithStacktraceDepth is like WithStacktrace, but limits the stack traces to
+// the given number of frames.  Stack traces with more frames end with a "..."
+// line.
+func WithStacktraceDepth(depth int) Option {
+	return func(l *LeveledLogger) {
+		WithStacktrace()(l)
+		l.stackDepth = depth
+	}
+}
+
+// stacktrace formats the stack of the caller of the logging method with up to
+// depth frames.
+func stacktrace(depth int) string {
+	frames := callerFrames(depth + 1)
+
+	var sb strings.Builder
+
+	for i, frame := range frames {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+
+		if i == depth {
+			sb.WriteString("...")
+
+			break
+		}
+
+		sb.WriteString(frame.Function)
+		sb.WriteString("\n\t")
+		sb.WriteString(frame.File)
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(frame.Line))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,103 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// decodeStacks returns the stack field of each JSON line written to b, or an
+// empty string for lines without a stack trace.
+func decodeStacks(t *testing.T, b *bytes.Buffer) []string {
+	t.Helper()
+
+	var stacks []string
+
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var fields map[string]string
+		if err := json.Unmarshal([]byte(line[6:]), &fields); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+
+		stacks = append(stacks, fields[StacktraceKey])
+	}
+
+	return stacks
+}
+
+func TestWithStacktrace(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithStacktrace())
+
+	With(l, "key", "value").Error("msg", "with stack")
+	l.Info("msg", "without stack")
+
+	stacks := decodeStacks(t, &b)
+	if len(stacks) != 2 {
+		t.Fatalf("got %d lines, want 2", len(stacks))
+	}
+
+	frames := strings.Split(stacks[0], "\n")
+	if !strings.HasSuffix(frames[0], ".TestWithStacktrace") {
+		t.Errorf("stack trace does not start with the test function:\n%s", stacks[0])
+	}
+
+	if !strings.HasPrefix(frames[1], "\t") || !strings.Contains(frames[1], "stacktrace_test.go:") {
+		t.Errorf("stack trace does not have the test file:\n%s", stacks[0])
+	}
+
+	if stacks[1] != "" {
+		t.Errorf("unexpected stack trace for an Info message:\n%s", stacks[1])
+	}
+}
+
+func TestWithStacktraceLevel(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithFilterLevel(level.All),
+		WithStacktraceLevel(level.Debug))
+
+	l.Error("msg", "error")
+	l.Info("msg", "info")
+	l.Debug("msg", "debug")
+	l.Trace("msg", "trace")
+
+	for i, stack := range decodeStacks(t, &b) {
+		if got, want := stack != "", i < 3; got != want {
+			t.Errorf("line %d: got stack trace %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestWithStacktraceDepth(t *testing.T) {
+	var b bytes.Buffer
+	l := NewLeveledLogger(WithLogger(log.New(&b, "", 0)), WithStacktraceDepth(1))
+
+	l.Error("msg", "error")
+
+	stacks := decodeStacks(t, &b)
+
+	frames := strings.Split(stacks[0], "\n")
+	if len(frames) != 3 || frames[2] != "..." {
+		t.Errorf("stack trace was not truncated to one frame:\n%s", stacks[0])
+	}
+}
@@ -0,0 +1,151 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"log"
+	"strings"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// StdlibWriter is an io.Writer forwarding the lines written by a log.Logger of
+// the standard library to a Logger, e.g. to capture the output of third-party
+// libraries calling log.Printf.  The prefix and the date and time added by
+// the log.Logger are stripped, the file and line added by its Lshortfile and
+// Llongfile flags are forwarded as a CallerKey field, and the rest of the
+// message as the MessageKey field.  When the LeveledLogger reports callers,
+// see WithCaller, the file and line are reported as the caller.
+//
+// Each write is forwarded as one message, as a log.Logger writes each message
+// with a single write, and the newlines inside the message are kept.  A
+// StdlibWriter is safe for concurrent use if its Logger is.
+type StdlibWriter struct {
+	log    Logger
+	lvl    level.Level
+	flags  int
+	prefix string
+}
+
+// StdlibOption configures a StdlibWriter.
+type StdlibOption func(*StdlibWriter)
+
+// WithStdlibLevel returns a StdlibOption that sets the level of the messages
+// forwarded by the StdlibWriter, level.Info by default.
+func WithStdlibLevel(lvl level.Level) StdlibOption {
+	return func(w *StdlibWriter) {
+		w.lvl = lvl
+	}
+}
+
+// WithStdlibFormat returns a StdlibOption that sets the flags and prefix of
+// the log.Logger writing to the StdlibWriter, log.LstdFlags and no prefix by
+// default.  They must match those of the log.Logger for its output to be
+// stripped.
+func WithStdlibFormat(flags int, prefix string) StdlibOption {
+	return func(w *StdlibWriter) {
+		w.flags = flags
+		w.prefix = prefix
+	}
+}
+
+// NewStdlibWriter returns a new StdlibWriter forwarding the lines written to
+// it to l.  Its defaults can be changed by providing StdlibOptions.
+func NewStdlibWriter(l Logger, opts ...StdlibOption) *StdlibWriter {
+	w := &StdlibWriter{log: l, lvl: level.Info, flags: log.LstdFlags}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// RedirectStdlib sets the output of the standard logger of the log package to
+// a StdlibWriter forwarding its lines to l, using the current flags and prefix
+// of the standard logger, and returns a function restoring its previous
+// output, flags and prefix.  The flags and prefix must not be changed until
+// the output is restored.
+func RedirectStdlib(l Logger, opts ...StdlibOption) (restore func()) {
+	out, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+
+	opts = append([]StdlibOption{WithStdlibFormat(flags, prefix)}, opts...)
+	log.SetOutput(NewStdlibWriter(l, opts...))
+
+	return func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}
+}
+
+// Write implements the io.Writer interface, forwarding p as a message.
+func (w *StdlibWriter) Write(p []byte) (int, error) {
+	logAt(w.log, w.lvl, w.keyvals(string(bytes.TrimSuffix(p, []byte{'\n'}))))
+
+	return len(p), nil
+}
+
+// Lengths of the date and times written by a log.Logger.
+const (
+	stdlibDateLen         = len("2006/01/02")
+	stdlibTimeLen         = len("15:04:05")
+	stdlibMicrosecondsLen = len("15:04:05.000000")
+)
+
+// keyvals returns the keyvals of a message written by a log.Logger.
+func (w *StdlibWriter) keyvals(line string) []interface{} {
+	if w.flags&log.Lmsgprefix == 0 {
+		line = strings.TrimPrefix(line, w.prefix)
+	}
+
+	if w.flags&log.Ldate != 0 {
+		line = trimToken(line, stdlibDateLen)
+	}
+
+	if w.flags&log.Lmicroseconds != 0 {
+		line = trimToken(line, stdlibMicrosecondsLen)
+	} else if w.flags&log.Ltime != 0 {
+		line = trimToken(line, stdlibTimeLen)
+	}
+
+	var keyvals []interface{}
+
+	if w.flags&(log.Lshortfile|log.Llongfile) != 0 {
+		if i := strings.Index(line, ": "); i > 0 && strings.Contains(line[:i], ":") {
+			keyvals = append(keyvals, CallerKey, callerOverride(line[:i]))
+			line = line[i+2:]
+		}
+	}
+
+	if w.flags&log.Lmsgprefix != 0 {
+		line = strings.TrimPrefix(line, w.prefix)
+	}
+
+	return append(keyvals, MessageKey, line)
+}
+
+// trimToken removes the token of the given length at the start of the line if
+// it is followed by a space.
+func trimToken(line string, length int) string {
+	if len(line) > length && line[length] == ' ' {
+		return line[length+1:]
+	}
+
+	return line
+}
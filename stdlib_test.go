@@ -0,0 +1,133 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+func TestStdlibWriter(t *testing.T) {
+	tests := map[string]struct {
+		flags  int
+		prefix string
+		want   string
+	}{
+		"default": {
+			log.LstdFlags, "",
+			`{"msg":"hello: world"}`,
+		},
+		"prefix_micro_file": {
+			log.Ldate | log.Lmicroseconds | log.Lshortfile, "[lib] ",
+			`{"caller":"stdlib_test.go:LINE","msg":"hello: world"}`,
+		},
+		"msgprefix_utc_longfile": {
+			log.Ltime | log.LUTC | log.Llongfile | log.Lmsgprefix, "lib: ",
+			`{"caller":"FILE:LINE","msg":"hello: world"}`,
+		},
+		"no_flags": {
+			0, "",
+			`{"msg":"hello: world"}`,
+		},
+	}
+
+	for name, tc := range tests {
+		var b bytes.Buffer
+
+		l := NewLeveledLogger(WithOutput(&b), WithTimestamp(TimestampNone), WithFilterLevel(level.All))
+		std := log.New(NewStdlibWriter(l, WithStdlibLevel(level.Debug), WithStdlibFormat(tc.flags, tc.prefix)), tc.prefix, tc.flags)
+
+		_, file, line, _ := runtime.Caller(0)
+		std.Print("hello: world")
+
+		want := strings.Replace(tc.want, "LINE", strconv.Itoa(line+1), 1)
+		want = strings.Replace(want, "FILE", file, 1)
+
+		if got := strings.TrimSpace(b.String()); got != "debug "+want {
+			t.Errorf("%s: got %q, want %q", name, got, "debug "+want)
+		}
+	}
+}
+
+func TestRedirectStdlib(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithOutput(&b), WithTimestamp(TimestampNone))
+
+	log.SetPrefix("app ")
+	restore := RedirectStdlib(l, WithStdlibLevel(level.Error))
+
+	log.Printf("failed: %d", 42)
+	log.Printf("err: %v\n%s", "boom", "app 2021/03/04 10:06:07 stack")
+	restore()
+
+	want := `error {"msg":"failed: 42"}
+error {"msg":"err: boom\napp 2021/03/04 10:06:07 stack"}`
+
+	if got := strings.TrimSpace(b.String()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	if log.Prefix() != "app " || log.Flags() != log.LstdFlags || log.Writer() != os.Stderr {
+		t.Errorf("unexpected flags %d and prefix %q after restore", log.Flags(), log.Prefix())
+	}
+
+	log.SetPrefix("")
+}
+
+func TestStdlibWriter_caller(t *testing.T) {
+	tests := map[string]struct {
+		flags int
+		want  func(file string, line int) string
+	}{
+		"shortfile": {
+			log.Lshortfile,
+			func(file string, line int) string {
+				return filepath.Base(file) + ":" + strconv.Itoa(line)
+			},
+		},
+		"no_file": {
+			log.LstdFlags,
+			func(file string, line int) string {
+				return filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file) + ":" + strconv.Itoa(line)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		var b bytes.Buffer
+
+		l := NewLeveledLogger(WithOutput(&b), WithTimestamp(TimestampNone), WithCaller())
+		std := log.New(NewStdlibWriter(l, WithStdlibFormat(tc.flags, "")), "", tc.flags)
+
+		_, file, line, _ := runtime.Caller(0)
+		std.Print("hello")
+
+		want := `info  {"caller":"` + tc.want(file, line+1) + `","msg":"hello"}`
+		if got := strings.TrimSpace(b.String()); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
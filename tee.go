@@ -0,0 +1,194 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// TeeSink is a Logger receiving the messages of a Logger returned by Tee, with
+// the levels of the messages forwarded to it.  The zero Level forwards all
+// messages.
+type TeeSink struct {
+	Logger Logger
+	Level  level.Level
+}
+
+// Tee returns a new Logger that forwards the messages logged to it to each of
+// the sinks whose Level includes the level of the message.  For instance, to
+// write all messages to a file but only Error and Info messages to stderr:
+//
+//	l := log.Tee(
+//		log.TeeSink{Logger: log.NewLeveledLogger(log.WithOutput(f), log.WithFilterLevel(level.All))},
+//		log.TeeSink{Logger: log.NewLeveledLogger(), Level: level.Default},
+//	)
+//
+// When sinks are LeveledLoggers configured with the same Encoder and the same
+// reserved fields, the message is encoded once and the resulting line is
+// written by each of them.
+//
+// A sink that panics does not prevent the message from being forwarded to the
+// other sinks, which are sent an Error message reporting the failure instead.
+// Use asynchronous LeveledLoggers (see WithAsync) to keep slow sinks from
+// delaying the others.
+func Tee(sinks ...TeeSink) Logger {
+	t := &tee{sinks: make([]TeeSink, len(sinks))}
+
+	for i, s := range sinks {
+		if s.Level == level.None {
+			s.Level = level.All
+		}
+
+		t.sinks[i] = s
+	}
+
+	return t
+}
+
+type tee struct {
+	sinks []TeeSink
+}
+
+func (t *tee) Error(keyvals ...interface{}) {
+	t.log(level.Error, keyvals)
+}
+
+func (t *tee) Info(keyvals ...interface{}) {
+	t.log(level.Info, keyvals)
+}
+
+func (t *tee) Debug(keyvals ...interface{}) {
+	t.log(level.Debug, keyvals)
+}
+
+func (t *tee) Trace(keyvals ...interface{}) {
+	t.log(level.Trace, keyvals)
+}
+
+func (t *tee) log(lvl level.Level, keyvals []interface{}) {
+	// lines holds the lines encoded for the LeveledLogger sinks, by their
+	// encoding configuration.
+	var lines map[encodeKey][]byte
+
+	for i, s := range t.sinks {
+		if s.Level&lvl == 0 {
+			continue
+		}
+
+		if r := forward(s.Logger, lvl, keyvals, &lines); r != nil {
+			t.sinkFailed(i, r)
+		}
+	}
+}
+
+// sinkFailed reports the panic of sink i to the other sinks.
+func (t *tee) sinkFailed(i int, r interface{}) {
+	keyvals := []interface{}{MessageKey, "log sink failed", "sink", i, "error", fmt.Sprint(r)}
+
+	for j, s := range t.sinks {
+		if j != i && s.Level&level.Error != 0 {
+			forward(s.Logger, level.Error, keyvals, nil)
+		}
+	}
+}
+
+// forward logs the message to l, reusing the line encoded for another
+// LeveledLogger with the same encoding configuration if lines is not nil.  It
+// returns the value of the panic of l, if any.
+func forward(l Logger, lvl level.Level, keyvals []interface{}, lines *map[encodeKey][]byte) (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+
+	ll, ok := l.(*LeveledLogger)
+	if !ok || lines == nil {
+		logAt(l, lvl, keyvals)
+
+		return nil
+	}
+
+	if !ll.enabled(lvl, keyvals) {
+		return nil
+	}
+
+	key, ok := ll.encodeKey()
+	if !ok {
+		ll.log(lvl, keyvals...)
+
+		return nil
+	}
+
+	line, found := (*lines)[key]
+	if !found {
+		line = ll.line(lvl, keyvals)
+
+		if *lines == nil {
+			*lines = make(map[encodeKey][]byte)
+		}
+
+		(*lines)[key] = line
+	}
+
+	ll.write(lvl, line)
+
+	return nil
+}
+
+// encodeKey holds the configuration of a LeveledLogger that determines the
+// lines it writes.
+type encodeKey struct {
+	encoder       Encoder
+	keys          KeyNames
+	caller        callerMode
+	stackLevel    level.Level
+	stackDepth    int
+	timestamps    bool
+	timePlacement TimestampPlacement
+	timeFormat    string
+	timeLocation  *time.Location
+	stdTimestamps bool
+}
+
+// timeNow is the code pointer of time.Now, the default clock.
+var timeNow = reflect.ValueOf(time.Now).Pointer()
+
+// encodeKey returns the encoding configuration of the logger, and false if it
+// cannot be compared to others because its Encoder is not comparable, or
+// because it has a clock set with WithClock, as functions cannot be compared:
+// the method values of different instances share the same code pointer.
+func (l *LeveledLogger) encodeKey() (encodeKey, bool) {
+	if !reflect.TypeOf(l.encoder).Comparable() || reflect.ValueOf(l.clock).Pointer() != timeNow {
+		return encodeKey{}, false
+	}
+
+	return encodeKey{
+		encoder:       l.encoder,
+		keys:          l.keys,
+		caller:        l.caller,
+		stackLevel:    l.stackLevel,
+		stackDepth:    l.stackDepth,
+		timestamps:    l.timestamps,
+		timePlacement: l.timePlacement,
+		timeFormat:    l.timeFormat,
+		timeLocation:  l.timeLocation,
+		stdTimestamps: l.stdTimestamps,
+	}, true
+}
@@ -0,0 +1,134 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cobaltspeech/log/pkg/level"
+)
+
+// countingEncoder counts the entries it encodes.
+type countingEncoder struct {
+	Encoder
+	n int
+}
+
+func (e *countingEncoder) Encode(entry *Entry) ([]byte, error) {
+	e.n++
+
+	return e.Encoder.Encode(entry)
+}
+
+// panicLogger panics on each call.
+type panicLogger struct{}
+
+func (panicLogger) Error(keyvals ...interface{}) { panic("broken sink") }
+func (panicLogger) Info(keyvals ...interface{})  { panic("broken sink") }
+func (panicLogger) Debug(keyvals ...interface{}) { panic("broken sink") }
+func (panicLogger) Trace(keyvals ...interface{}) { panic("broken sink") }
+
+func TestTee(t *testing.T) {
+	var file, stderr, other bytes.Buffer
+
+	enc := &countingEncoder{Encoder: NewLogfmtEncoder()}
+	opts := []Option{WithEncoder(enc), WithTimestamp(TimestampNone), WithFilterLevel(level.All)}
+
+	l := Tee(
+		TeeSink{Logger: NewLeveledLogger(append(opts, WithOutput(&file))...)},
+		TeeSink{Logger: NewLeveledLogger(append(opts, WithOutput(&stderr))...), Level: level.Default},
+		TeeSink{Logger: NewLeveledLogger(WithOutput(&other), WithTimestamp(TimestampNone),
+			WithEncoder(NewLogfmtEncoder()), WithFilterLevel(level.Error)), Level: level.Error | level.Info},
+	)
+
+	l.Trace("msg", "trace")
+	l.Info("msg", "info")
+	l.Error("msg", "error")
+
+	tests := map[string]struct {
+		got  string
+		want string
+	}{
+		"file":   {file.String(), "level=trace msg=trace\nlevel=info msg=info\nlevel=error msg=error\n"},
+		"stderr": {stderr.String(), "level=info msg=info\nlevel=error msg=error\n"},
+		"other":  {other.String(), "level=error msg=error\n"},
+	}
+
+	for name, tc := range tests {
+		if tc.got != tc.want {
+			t.Errorf("%s: got %q, want %q", name, tc.got, tc.want)
+		}
+	}
+
+	// The entries shared by the first two sinks are only encoded once.
+	if enc.n != 3 {
+		t.Errorf("got %d encoded entries, want 3", enc.n)
+	}
+}
+
+// offsetClock is a clock returning a fixed time shifted by an offset.
+type offsetClock struct {
+	offset time.Duration
+}
+
+func (c *offsetClock) Now() time.Time {
+	return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC).Add(c.offset)
+}
+
+func TestTee_clocks(t *testing.T) {
+	var a, b bytes.Buffer
+
+	enc := &countingEncoder{Encoder: NewLogfmtEncoder()}
+	clockA, clockB := &offsetClock{}, &offsetClock{time.Hour}
+
+	l := Tee(
+		TeeSink{Logger: NewLeveledLogger(WithOutput(&a), WithEncoder(enc), WithClock(clockA.Now))},
+		TeeSink{Logger: NewLeveledLogger(WithOutput(&b), WithEncoder(enc), WithClock(clockB.Now))},
+	)
+
+	l.Info("msg", "hello")
+
+	if got, want := a.String(), "2021-03-04T05:06:07Z level=info msg=hello\n"; got != want {
+		t.Errorf("first sink: got %q, want %q", got, want)
+	}
+
+	if got, want := b.String(), "2021-03-04T06:06:07Z level=info msg=hello\n"; got != want {
+		t.Errorf("second sink: got %q, want %q", got, want)
+	}
+
+	if enc.n != 2 {
+		t.Errorf("got %d encoded entries, want 2", enc.n)
+	}
+}
+
+func TestTee_failingSink(t *testing.T) {
+	var b bytes.Buffer
+
+	l := Tee(
+		TeeSink{Logger: panicLogger{}},
+		TeeSink{Logger: NewLeveledLogger(WithOutput(&b), WithTimestamp(TimestampNone), WithEncoder(NewLogfmtEncoder()))},
+	)
+
+	l.Info("msg", "hello")
+
+	want := "level=error msg=\"log sink failed\" sink=0 error=\"broken sink\"\nlevel=info msg=hello\n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
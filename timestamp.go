@@ -0,0 +1,121 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"strconv"
+	"time"
+)
+
+// TimeKey is the default key of the field holding the timestamp of messages
+// when the TimestampField placement is used.  See WithKeyNames.
+const TimeKey = "ts"
+
+// stdTimeFormat is the layout of the timestamp prefix of log.LstdFlags.
+const stdTimeFormat = "2006/01/02 15:04:05 "
+
+// TimestampPlacement enumerates where the LeveledLogger writes the timestamp
+// of log messages.
+type TimestampPlacement byte
+
+const (
+	// TimestampPrefix writes the timestamp before the encoded message.
+	TimestampPrefix TimestampPlacement = iota
+
+	// TimestampField writes the timestamp as a TimeKey field of the message,
+	// e.g. inside the JSON object written by the JSONEncoder.
+	TimestampField
+
+	// TimestampNone does not write timestamps.
+	TimestampNone
+)
+
+// Time formats supported by WithTimeFormat, in addition to any layout
+// accepted by time.Time.Format.
+const (
+	TimeFormatRFC3339     = time.RFC3339
+	TimeFormatRFC3339Nano = time.RFC3339Nano
+
+	// TimeFormatUnixMilli writes the number of milliseconds elapsed since
+	// January 1, 1970 UTC.  In a TimestampField, it is written as a JSON
+	// number rather than a string.
+	TimeFormatUnixMilli = "unixms"
+)
+
+// WithTimestamp returns an Option that configures where the LeveledLogger
+// writes the timestamp of log messages.
+//
+// By default, timestamps are written by the stdlib log.Logger as a prefix in
+// local time with second precision (log.LstdFlags).  Once WithTimestamp,
+// WithTimeFormat, WithTimeZone or WithClock is used, the LeveledLogger formats
+// timestamps itself, in RFC3339Nano format and in UTC unless configured
+// otherwise, and the log.Logger created for WithOutput or stderr does not add
+// its own.  The flags of a log.Logger provided with WithLogger are not
+// changed.
+func WithTimestamp(placement TimestampPlacement) Option {
+	return func(l *LeveledLogger) {
+		l.timestamps = true
+		l.timePlacement = placement
+	}
+}
+
+// WithTimeFormat returns an Option that configures the format of timestamps,
+// either TimeFormatUnixMilli or a layout accepted by time.Time.Format such as
+// TimeFormatRFC3339 and TimeFormatRFC3339Nano.  See WithTimestamp.
+func WithTimeFormat(format string) Option {
+	return func(l *LeveledLogger) {
+		l.timestamps = true
+		l.timeFormat = format
+	}
+}
+
+// WithTimeZone returns an Option that configures the time zone of timestamps.
+// See WithTimestamp.
+func WithTimeZone(loc *time.Location) Option {
+	return func(l *LeveledLogger) {
+		l.timestamps = true
+		l.timeLocation = loc
+	}
+}
+
+// WithClock returns an Option that configures the function returning the
+// time of log messages, e.g. to get deterministic timestamps in tests.  See
+// WithTimestamp.
+func WithClock(now func() time.Time) Option {
+	return func(l *LeveledLogger) {
+		l.timestamps = true
+		l.clock = now
+	}
+}
+
+// unixMilli is a timestamp in milliseconds since the Unix epoch, written as a
+// JSON number.
+type unixMilli int64
+
+func (ms unixMilli) MarshalJSON() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(ms), 10), nil //nolint:gomnd // base 10
+}
+
+// timestamp returns the value of the timestamp for t, according to the
+// configured format and time zone.
+func (l *LeveledLogger) timestamp(t time.Time) interface{} {
+	if l.timeFormat == TimeFormatUnixMilli {
+		return unixMilli(t.UnixNano() / int64(time.Millisecond))
+	}
+
+	return t.In(l.timeLocation).Format(l.timeFormat)
+}
@@ -0,0 +1,97 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// fixedClock returns a clock that always returns the same time.
+func fixedClock() func() time.Time {
+	t := time.Date(2021, 3, 4, 5, 6, 7, 890123456, time.FixedZone("EST", -5*60*60))
+
+	return func() time.Time {
+		return t
+	}
+}
+
+func TestWithTimestamp(t *testing.T) {
+	tests := map[string]struct {
+		opts []Option
+		want string
+	}{
+		"clock": {
+			[]Option{WithClock(fixedClock())},
+			`2021-03-04T10:06:07.890123456Z info  {"msg":"hello"}` + "\n",
+		},
+		"field": {
+			[]Option{WithClock(fixedClock()), WithTimestamp(TimestampField)},
+			`info  {"ts":"2021-03-04T10:06:07.890123456Z","msg":"hello"}` + "\n",
+		},
+		"none": {
+			[]Option{WithTimestamp(TimestampNone)},
+			`info  {"msg":"hello"}` + "\n",
+		},
+		"rfc3339": {
+			[]Option{WithClock(fixedClock()), WithTimestamp(TimestampField), WithTimeFormat(TimeFormatRFC3339)},
+			`info  {"ts":"2021-03-04T10:06:07Z","msg":"hello"}` + "\n",
+		},
+		"unix_milli_field": {
+			[]Option{WithClock(fixedClock()), WithTimestamp(TimestampField), WithTimeFormat(TimeFormatUnixMilli)},
+			`info  {"ts":1614852367890,"msg":"hello"}` + "\n",
+		},
+		"unix_milli_prefix": {
+			[]Option{WithClock(fixedClock()), WithTimeFormat(TimeFormatUnixMilli)},
+			`1614852367890 info  {"msg":"hello"}` + "\n",
+		},
+		"time_zone": {
+			[]Option{WithClock(fixedClock()), WithTimeZone(time.FixedZone("X", 60*60))},
+			`2021-03-04T11:06:07.890123456+01:00 info  {"msg":"hello"}` + "\n",
+		},
+		"logfmt": {
+			[]Option{WithClock(fixedClock()), WithTimestamp(TimestampField), WithEncoder(NewLogfmtEncoder())},
+			"level=info ts=2021-03-04T10:06:07.890123456Z msg=hello\n",
+		},
+	}
+
+	for name, tc := range tests {
+		var b bytes.Buffer
+
+		l := NewLeveledLogger(append([]Option{WithOutput(&b)}, tc.opts...)...)
+		l.Info("msg", "hello")
+
+		if got := b.String(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestWithTimestamp_encodeFailure(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithOutput(&b), WithClock(fixedClock()), WithTimestamp(TimestampField),
+		WithEncoder(NewLogfmtEncoder()))
+	l.Info("msg", &failingTextMarshaler{})
+
+	want := `level=error ts=2021-03-04T10:06:07.890123456Z msg="logging failure" error="invalid value"` + "\n"
+
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+)
+
+// Keys of the fields added by WithTrace.  They match the default keys of the
+// otlp package, so that the IDs are exported as the trace context of the log
+// records.
+const (
+	TraceIDKey = "trace_id"
+	SpanIDKey  = "span_id"
+)
+
+// TraceExtractor returns the trace and span IDs of the operation of ctx, as
+// hexadecimal strings, and whether ctx carries them.  Extractors may be written
+// for tracing libraries, e.g. using the span context of OpenTelemetry.
+type TraceExtractor func(ctx context.Context) (traceID, spanID string, ok bool)
+
+// traceParentKey is the key of the traceparent values stored in contexts.
+type traceParentKey struct{}
+
+// Lengths of the fields of version 00 traceparent values.
+const (
+	traceParentLength = 55
+	traceIDLength     = 32
+	spanIDLength      = 16
+)
+
+// NewTraceParentContext returns a copy of ctx carrying the value of a W3C
+// traceparent header, e.g. received by a server, which TraceParentExtractor
+// extracts the IDs from.
+func NewTraceParentContext(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceparent)
+}
+
+// TraceParentExtractor is a TraceExtractor returning the IDs of the traceparent
+// value carried by ctx, see NewTraceParentContext.
+func TraceParentExtractor(ctx context.Context) (traceID, spanID string, ok bool) {
+	traceparent, _ := ctx.Value(traceParentKey{}).(string)
+
+	return ParseTraceParent(traceparent)
+}
+
+// ParseTraceParent returns the trace and span IDs of the value of a W3C
+// traceparent header, e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+// and whether the value is valid.  Values of future versions are accepted as
+// long as they start with the fields of version 00.
+func ParseTraceParent(traceparent string) (traceID, spanID string, ok bool) {
+	if len(traceparent) < traceParentLength {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(traceparent, "-", 5) //nolint:gomnd // four fields and the rest of future versions
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[3]) != 2 {
+		return "", "", false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	switch {
+	case !isLowerHex(version) || version == "ff" || !isLowerHex(flags),
+		version == "00" && len(parts) > 4,
+		len(traceID) != traceIDLength || !isHexID(traceID),
+		len(spanID) != spanIDLength || !isHexID(spanID):
+		return "", "", false
+	}
+
+	return traceID, spanID, true
+}
+
+// WithTrace returns a new contextual Logger adding the trace and span IDs of
+// ctx to the messages, with the TraceIDKey and SpanIDKey keys, so that they can
+// be joined with traces.  The IDs are returned by the first of the extractors
+// that finds them in ctx, or by TraceParentExtractor if no extractor is given.
+// It returns l if no IDs are found.
+func WithTrace(ctx context.Context, l Logger, extractors ...TraceExtractor) Logger {
+	if len(extractors) == 0 {
+		extractors = []TraceExtractor{TraceParentExtractor}
+	}
+
+	for _, extract := range extractors {
+		if traceID, spanID, ok := extract(ctx); ok {
+			return With(l, TraceIDKey, traceID, SpanIDKey, spanID)
+		}
+	}
+
+	return l
+}
+
+// isHexID reports whether s is a non-zero ID made of lowercase hexadecimal
+// digits.
+func isHexID(s string) bool {
+	return isLowerHex(s) && strings.Trim(s, "0") != ""
+}
+
+// isLowerHex reports whether s is made of lowercase hexadecimal digits.
+func isLowerHex(s string) bool {
+	_, err := hex.DecodeString(s)
+
+	return err == nil && strings.ToLower(s) == s
+}
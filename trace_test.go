@@ -0,0 +1,86 @@
+/*
+   Copyright (2021) Cobalt Speech and Language Inc.
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const (
+		traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanID  = "00f067aa0ba902b7"
+	)
+
+	tests := map[string]struct {
+		traceparent string
+		ok          bool
+	}{
+		"valid":           {"00-" + traceID + "-" + spanID + "-01", true},
+		"future_version":  {"cc-" + traceID + "-" + spanID + "-01-extra", true},
+		"empty":           {"", false},
+		"extra_in_v00":    {"00-" + traceID + "-" + spanID + "-01-extra", false},
+		"invalid_version": {"ff-" + traceID + "-" + spanID + "-01", false},
+		"uppercase":       {"00-" + strings.ToUpper(traceID) + "-" + spanID + "-01", false},
+		"zero_trace":      {"00-" + strings.Repeat("0", 32) + "-" + spanID + "-01", false},
+		"zero_span":       {"00-" + traceID + "-" + strings.Repeat("0", 16) + "-01", false},
+		"short_span":      {"00-" + traceID + "-" + spanID[1:] + "-001", false},
+		"invalid_flags":   {"00-" + traceID + "-" + spanID + "-0x", false},
+	}
+
+	for name, tc := range tests {
+		gotTrace, gotSpan, ok := ParseTraceParent(tc.traceparent)
+		if ok != tc.ok {
+			t.Errorf("%s: got ok %v, want %v", name, ok, tc.ok)
+
+			continue
+		}
+
+		if ok && (gotTrace != traceID || gotSpan != spanID) {
+			t.Errorf("%s: got IDs %q and %q", name, gotTrace, gotSpan)
+		}
+	}
+}
+
+func TestWithTrace(t *testing.T) {
+	var b bytes.Buffer
+
+	l := NewLeveledLogger(WithOutput(&b), WithTimestamp(TimestampNone))
+
+	ctx := NewTraceParentContext(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	custom := func(ctx context.Context) (string, string, bool) {
+		return "0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", true
+	}
+	none := func(ctx context.Context) (string, string, bool) {
+		return "", "", false
+	}
+
+	WithTrace(ctx, l).Info("msg", "traceparent")
+	WithTrace(ctx, l, none, custom).Info("msg", "custom")
+	WithTrace(context.Background(), l).Info("msg", "none")
+
+	want := `info  {"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","msg":"traceparent"}
+info  {"trace_id":"0af7651916cd43dd8448eb211c80319c","span_id":"b7ad6b7169203331","msg":"custom"}
+info  {"msg":"none"}`
+
+	if got := strings.TrimSpace(b.String()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}